@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"trendyol-scraper/config"
+	"trendyol-scraper/frontier"
+	"trendyol-scraper/messaging"
+	"trendyol-scraper/migrations"
+	"trendyol-scraper/outbox"
+	"trendyol-scraper/scraper"
+	"trendyol-scraper/storage"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// app lazily builds the dependencies a command needs, so that e.g. a JSON
+// scrape does not require Kafka and `migrate status` does not touch the bus.
+type app struct {
+	cfg         *config.Config
+	autoMigrate bool
+	migrated    bool
+
+	db             *gorm.DB
+	storageHandler storage.StorageHandler
+	messageBus     messaging.Bus
+	limiter        *scraper.RateLimiter
+	retry          *scraper.RetryPolicy
+	proxies        *scraper.ProxyPool
+	crawlFrontier  frontier.Frontier
+}
+
+func newApp(cfg *config.Config, autoMigrate bool) *app {
+	return &app{cfg: cfg, autoMigrate: autoMigrate}
+}
+
+// openDB connects to Postgres without touching the schema.
+func (a *app) openDB() (*gorm.DB, error) {
+	if a.db != nil {
+		return a.db, nil
+	}
+
+	db, err := gorm.Open(postgres.Open(a.cfg.DSN()), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("error initializing database: %w", err)
+	}
+	a.db = db
+	return db, nil
+}
+
+// database connects to Postgres and, unless disabled, applies pending
+// migrations.
+func (a *app) database() (*gorm.DB, error) {
+	db, err := a.openDB()
+	if err != nil {
+		return nil, err
+	}
+
+	if a.autoMigrate && !a.migrated {
+		migrator, err := migrations.NewMigrator(db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize migrations: %w", err)
+		}
+		applied, err := migrator.Up()
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+		a.migrated = true
+	}
+
+	return db, nil
+}
+
+func (a *app) storage() (storage.StorageHandler, error) {
+	if a.storageHandler != nil {
+		return a.storageHandler, nil
+	}
+
+	if a.cfg.Scraper.OutputFormat == "db" {
+		db, err := a.database()
+		if err != nil {
+			return nil, err
+		}
+		handler, err := storage.NewDatabaseStorage(db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize database storage: %w", err)
+		}
+		a.storageHandler = handler
+	} else {
+		a.storageHandler = storage.NewJSONStorage(a.cfg)
+	}
+
+	return a.storageHandler, nil
+}
+
+func (a *app) bus() (messaging.Bus, error) {
+	if a.messageBus != nil {
+		return a.messageBus, nil
+	}
+
+	bus, err := messaging.New(a.cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.messageBus = bus
+	return bus, nil
+}
+
+func (a *app) notificationService() (*NotificationService, error) {
+	db, err := a.database()
+	if err != nil {
+		return nil, err
+	}
+	bus, err := a.bus()
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotificationService{
+		db:         db,
+		subscriber: bus,
+		topic:      a.cfg.Kafka.Topic,
+	}, nil
+}
+
+func (a *app) analysisService() (*ProductAnalysisService, error) {
+	db, err := a.database()
+	if err != nil {
+		return nil, err
+	}
+	storageHandler, err := a.storage()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProductAnalysisService{
+		db:             db,
+		storageHandler: storageHandler,
+		topic:          a.cfg.Kafka.Topic,
+		priceHistory:   a.cfg.Scraper.OutputFormat == "db",
+	}, nil
+}
+
+// inProcessBus reports whether the bus lives only as long as the process,
+// so events published to it reach a consumer only within serve.
+func (a *app) inProcessBus() bool {
+	return a.cfg.Bus.Driver == "memory"
+}
+
+func (a *app) outboxRelay() (*outbox.Relay, error) {
+	db, err := a.database()
+	if err != nil {
+		return nil, err
+	}
+	bus, err := a.bus()
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(a.cfg.Outbox.PollIntervalSeconds) * time.Second
+	return outbox.NewRelay(db, bus, a.cfg.Outbox.BatchSize, interval), nil
+}
+
+// rateLimiter is shared by every scraper of the process so they pace their
+// requests to a host together.
+func (a *app) rateLimiter() *scraper.RateLimiter {
+	if a.limiter == nil {
+		a.limiter = scraper.NewRateLimiter(a.cfg)
+	}
+	return a.limiter
+}
+
+// retryPolicy is shared like the rate limiter, so its error counts cover
+// the whole run.
+func (a *app) retryPolicy() *scraper.RetryPolicy {
+	if a.retry == nil {
+		a.retry = scraper.NewRetryPolicy(a.cfg, a.rateLimiter())
+	}
+	return a.retry
+}
+
+// proxyPool is shared by every scraper of the process, so a proxy that is
+// resting is rested for all of them.
+func (a *app) proxyPool() (*scraper.ProxyPool, error) {
+	if a.proxies != nil {
+		return a.proxies, nil
+	}
+
+	pool, err := scraper.NewProxyPool(a.cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.rateLimiter().UseProxies(pool)
+	a.proxies = pool
+	return pool, nil
+}
+
+// frontier returns the crawl frontier selected by frontier.driver.
+func (a *app) frontier() (frontier.Frontier, error) {
+	if a.crawlFrontier != nil {
+		return a.crawlFrontier, nil
+	}
+
+	// Scrapers retry pages themselves (scraper.retry), so a failure they
+	// report is final for the run; the next run queues it again
+	const maxAttempts = 1
+	switch a.cfg.Frontier.Driver {
+	case "", "memory":
+		a.crawlFrontier = frontier.NewMemory(maxAttempts)
+	case "file":
+		f, err := frontier.NewFile(a.cfg.Frontier.FilePath, maxAttempts)
+		if err != nil {
+			return nil, err
+		}
+		a.crawlFrontier = f
+	case "db":
+		db, err := a.database()
+		if err != nil {
+			return nil, err
+		}
+		a.crawlFrontier = frontier.NewDB(db, maxAttempts)
+	default:
+		return nil, fmt.Errorf("unknown frontier driver %q (want memory, file or db)", a.cfg.Frontier.Driver)
+	}
+
+	return a.crawlFrontier, nil
+}
+
+// close flushes and closes the message bus before the database pool, so
+// nothing that is still publishing loses its connection first.
+func (a *app) close() {
+	if a.messageBus != nil {
+		if err := a.messageBus.Close(); err != nil {
+			log.Printf("Failed to close message bus: %v", err)
+		}
+	}
+	if a.db != nil {
+		if sqlDB, err := a.db.DB(); err == nil {
+			if err := sqlDB.Close(); err != nil {
+				log.Printf("Failed to close database pool: %v", err)
+			}
+		}
+	}
+}
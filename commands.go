@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"trendyol-scraper/models"
+	"trendyol-scraper/scraper"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+)
+
+type command struct {
+	summary string
+	usage   []string
+	run     func(ctx context.Context, a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"scrape": {
+		summary: "scrape categories or products from the site",
+		usage: []string{
+			"scrape categories",
+			"scrape products --category URL [--category URL ...] [--mode api|browser]",
+			"scrape products --query TEXT",
+		},
+		run: runScrape,
+	},
+	"fixtures": {
+		summary: "check the HTML parsers against saved pages, without a browser",
+		usage:   []string{"fixtures check [--dir DIR] [--update]"},
+		run:     runFixtures,
+	},
+	"ingest": {
+		summary: "load a listing payload from disk and analyze it",
+		usage:   []string{"ingest --file data.json"},
+		run:     runIngest,
+	},
+	"analyze": {
+		summary: "periodically re-check favorited products for price drops",
+		usage:   []string{"analyze"},
+		run:     runAnalyze,
+	},
+	"config": {
+		summary: "show or validate the effective configuration",
+		usage:   []string{"config print [--redacted=false]", "config validate"},
+		run:     runConfig,
+	},
+	"consume": {
+		summary: "consume price drop events and send notifications",
+		usage:   []string{"consume notifications"},
+		run:     runConsume,
+	},
+	"migrate": {
+		summary: "manage the database schema",
+		usage:   []string{"migrate up", "migrate down [--steps N]", "migrate status"},
+		run:     runMigrate,
+	},
+	"relay": {
+		summary: "publish pending outbox events to the message bus",
+		usage:   []string{"relay [--once]"},
+		run:     runRelay,
+	},
+	"serve": {
+		summary: "run the outbox relay, notification consumer and favorites analyzer together",
+		usage:   []string{"serve [--ingest data.json]"},
+		run:     runServe,
+	},
+}
+
+func runScrape(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: scrape categories|products")
+	}
+
+	proxies, err := a.proxyPool()
+	if err != nil {
+		return err
+	}
+	if proxies.Len() > 0 {
+		proxies.Check(ctx)
+		checkCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go proxies.Run(checkCtx)
+	}
+	defer func() { log.Printf("Scrape summary: %s", a.retryPolicy().Summary()) }()
+
+	switch args[0] {
+	case "categories":
+		fs := flag.NewFlagSet("scrape categories", flag.ContinueOnError)
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		storageHandler, err := a.storage()
+		if err != nil {
+			return err
+		}
+		crawlFrontier, err := a.frontier()
+		if err != nil {
+			return err
+		}
+
+		categories, scrapeErr := scraper.NewCategoryScraper(a.cfg, a.rateLimiter(), a.retryPolicy(), proxies, crawlFrontier).ScrapeCategories(ctx)
+		if len(categories) > 0 {
+			if err := storageHandler.SaveCategories(categories); err != nil {
+				return fmt.Errorf("failed to save categories: %w", err)
+			}
+			log.Printf("Saved %d top-level categories", len(categories))
+		}
+		return scrapeErr
+	case "products":
+		fs := flag.NewFlagSet("scrape products", flag.ContinueOnError)
+		var categoryURLs stringList
+		fs.Var(&categoryURLs, "category", "category listing URL to scrape; repeat for several categories")
+		query := fs.String("query", "", "search query to scrape instead of a category")
+		mode := fs.String("mode", "", "override scraper.mode (api or browser)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if (len(categoryURLs) == 0) == (*query == "") {
+			return fmt.Errorf("scrape products: exactly one of --category or --query is required")
+		}
+		if *mode != "" {
+			if *mode != "api" && *mode != "browser" {
+				return fmt.Errorf("scrape products: --mode must be api or browser, got %q", *mode)
+			}
+			a.cfg.Scraper.Mode = *mode
+		}
+
+		analysisSvc, err := a.analysisService()
+		if err != nil {
+			return err
+		}
+		crawlFrontier, err := a.frontier()
+		if err != nil {
+			return err
+		}
+
+		productScraper := scraper.NewProductScraper(a.cfg, a.rateLimiter(), a.retryPolicy(), proxies, crawlFrontier)
+		process := func(source string, products []models.Product) error {
+			// Keep what was scraped before a failure; the frontier remembers the rest
+			if len(products) == 0 {
+				return nil
+			}
+			if err := analysisSvc.ProcessProducts(ctx, products); err != nil {
+				return fmt.Errorf("failed to process products: %w", err)
+			}
+			log.Printf("Processed %d products from %s", len(products), source)
+			return flushOutbox(ctx, a)
+		}
+
+		if *query != "" {
+			products, scrapeErr := productScraper.SearchProducts(ctx, *query)
+			if err := process(fmt.Sprintf("query %q", *query), products); err != nil {
+				return err
+			}
+			return scrapeErr
+		}
+
+		// One scraper for all categories, so a product listed in several is
+		// scraped once; a failed category does not stop the others
+		var scrapeErrs []error
+		for _, categoryURL := range categoryURLs {
+			products, scrapeErr := productScraper.ScrapeProductsFromCategory(ctx, categoryURL)
+			if err := process(categoryURL, products); err != nil {
+				return err
+			}
+			if scrapeErr != nil {
+				if ctx.Err() != nil {
+					return scrapeErr
+				}
+				log.Printf("Failed to scrape %s: %v", categoryURL, scrapeErr)
+				scrapeErrs = append(scrapeErrs, fmt.Errorf("%s: %w", categoryURL, scrapeErr))
+			}
+		}
+		return errors.Join(scrapeErrs...)
+	default:
+		return fmt.Errorf("unknown scrape target %q (want categories or products)", args[0])
+	}
+}
+
+func runIngest(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("ingest", flag.ContinueOnError)
+	file := fs.String("file", "data.json", "listing payload to ingest")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	analysisSvc, err := a.analysisService()
+	if err != nil {
+		return err
+	}
+
+	products, err := scraper.NewMockProcessor(*file).ProcessMockData()
+	if err != nil {
+		return fmt.Errorf("failed to process mock data: %w", err)
+	}
+
+	if err := analysisSvc.ProcessProducts(ctx, products); err != nil {
+		return fmt.Errorf("failed to process products: %w", err)
+	}
+	return flushOutbox(ctx, a)
+}
+
+func runAnalyze(ctx context.Context, a *app, args []string) error {
+	analysisSvc, err := a.analysisService()
+	if err != nil {
+		return err
+	}
+	relay, err := a.outboxRelay()
+	if err != nil {
+		return err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	if a.inProcessBus() {
+		log.Printf("Leaving price drop events in the outbox: the memory bus has no consumer outside serve")
+	} else {
+		g.Go(func() error { return relay.Run(ctx) })
+	}
+	g.Go(func() error { return analysisSvc.PrioritizeFavoritedProducts(ctx) })
+	return g.Wait()
+}
+
+func runConsume(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 || args[0] != "notifications" {
+		return fmt.Errorf("usage: consume notifications")
+	}
+
+	notificationSvc, err := a.notificationService()
+	if err != nil {
+		return err
+	}
+
+	return notificationSvc.StartConsumer(ctx)
+}
+
+func runServe(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	ingestFile := fs.String("ingest", "", "listing payload to ingest once the consumer is running")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	analysisSvc, err := a.analysisService()
+	if err != nil {
+		return err
+	}
+	notificationSvc, err := a.notificationService()
+	if err != nil {
+		return err
+	}
+	relay, err := a.outboxRelay()
+	if err != nil {
+		return err
+	}
+
+	// If one component fails the others are stopped as well
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error { return relay.Run(ctx) })
+	g.Go(func() error {
+		if err := notificationSvc.StartConsumer(ctx); err != nil {
+			return fmt.Errorf("notification consumer stopped: %w", err)
+		}
+		return nil
+	})
+
+	// With the memory bus this runs the whole price drop flow in one process
+	if *ingestFile != "" {
+		g.Go(func() error {
+			products, err := scraper.NewMockProcessor(*ingestFile).ProcessMockData()
+			if err != nil {
+				return fmt.Errorf("failed to process mock data: %w", err)
+			}
+			if err := analysisSvc.ProcessProducts(ctx, products); err != nil {
+				return fmt.Errorf("failed to process products: %w", err)
+			}
+			return nil
+		})
+	}
+
+	g.Go(func() error { return analysisSvc.PrioritizeFavoritedProducts(ctx) })
+	return g.Wait()
+}
+
+func runRelay(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("relay", flag.ContinueOnError)
+	once := fs.Bool("once", false, "publish pending events and exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if a.inProcessBus() {
+		return fmt.Errorf("relay needs the kafka or file bus; with the memory bus events are relayed by serve")
+	}
+	if *once {
+		return flushOutbox(ctx, a)
+	}
+
+	relay, err := a.outboxRelay()
+	if err != nil {
+		return err
+	}
+	return relay.Run(ctx)
+}
+
+// flushOutbox publishes the events queued by a one-shot command instead of
+// leaving them for the next relay run. Events for the memory bus stay in the
+// outbox: nothing in this process would consume them before it exits.
+func flushOutbox(ctx context.Context, a *app) error {
+	if a.inProcessBus() {
+		log.Printf("Leaving price drop events in the outbox: the memory bus has no consumer outside serve")
+		return nil
+	}
+
+	relay, err := a.outboxRelay()
+	if err != nil {
+		return err
+	}
+
+	n, err := relay.Flush(ctx)
+	if n > 0 {
+		log.Printf("Published %d outbox events", n)
+	}
+	if err != nil {
+		return fmt.Errorf("events remain in the outbox: %w", err)
+	}
+	return nil
+}
+
+func runConfig(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: config print|validate")
+	}
+
+	switch args[0] {
+	case "print":
+		fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+		redacted := fs.Bool("redacted", true, "mask secrets such as passwords")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		cfg := a.cfg
+		if *redacted {
+			cfg = cfg.Redacted()
+		}
+		out, err := yaml.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(out))
+	case "validate":
+		if err := a.cfg.Validate(); err != nil {
+			return err
+		}
+		fmt.Println("configuration is valid")
+	default:
+		return fmt.Errorf("unknown config command %q (want print or validate)", args[0])
+	}
+
+	return nil
+}
+
+func runFixtures(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("usage: fixtures check [--dir DIR] [--update]")
+	}
+
+	fs := flag.NewFlagSet("fixtures check", flag.ContinueOnError)
+	dir := fs.String("dir", "scraper/testdata/pages", "directory with saved pages and golden outputs")
+	update := fs.Bool("update", false, "rewrite golden outputs from the current parser")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	results, err := scraper.CheckFixtures(*dir, scraper.NewProfiles(a.cfg), *update)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Printf("FAIL %s: %v\n", r.Name, r.Err)
+		case r.Updated:
+			fmt.Printf("UPDATED %s\n", r.Name)
+		default:
+			fmt.Printf("ok   %s\n", r.Name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d fixtures failed", failed, len(results))
+	}
+	return nil
+}
+
+// stringList collects a flag that may be given more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
@@ -2,33 +2,132 @@ package config
 
 import (
     "os"
+    "strconv"
+    "strings"
 
     "gopkg.in/yaml.v3"
 )
 
 type Config struct {
+    App struct {
+        ShutdownTimeoutSeconds int `yaml:"shutdown_timeout_seconds"`
+    } `yaml:"app"`
     Database struct {
         Host     string `yaml:"host"`
         Port     int    `yaml:"port"`
         User     string `yaml:"user"`
-        Password string `yaml:"password"`
+        Password string `yaml:"password" secret:"true"`
         Name     string `yaml:"name"`
+        SSLMode  string `yaml:"sslmode"` // disable, require, verify-ca or verify-full
+        SSLRootCert string `yaml:"sslrootcert"`
+        SSLCert     string `yaml:"sslcert"`
+        SSLKey      string `yaml:"sslkey"`
     } `yaml:"database"`
     Kafka struct {
-        Brokers []string `yaml:"brokers"`
-        Topic   string   `yaml:"topic"`
-        GroupID string   `yaml:"group_id"`
+        Brokers     []string `yaml:"brokers"`
+        Topic       string   `yaml:"topic"`
+        GroupID     string   `yaml:"group_id"`
+        ClientID    string   `yaml:"client_id"`
+        Version     string   `yaml:"version"`     // e.g. "2.8.0"; empty uses the sarama default
+        Compression string   `yaml:"compression"` // none, gzip, snappy, lz4 or zstd
+        Partitioner string   `yaml:"partitioner"` // hash, random or roundrobin
+        DeadLetterTopic string `yaml:"dead_letter_topic"` // gets messages that failed bus.max_retries times; empty logs and skips them
+        // Consumer group settings
+        RebalanceStrategy string `yaml:"rebalance_strategy"` // range, roundrobin or sticky
+        InitialOffset     string `yaml:"initial_offset"`     // oldest or newest, used when the group has no committed offset
+        SASL        struct {
+            Enabled   bool   `yaml:"enabled"`
+            Mechanism string `yaml:"mechanism"` // PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+            Username  string `yaml:"username"`
+            Password  string `yaml:"password" secret:"true"`
+        } `yaml:"sasl"`
+        TLS struct {
+            Enabled            bool   `yaml:"enabled"`
+            CAFile             string `yaml:"ca_file"`
+            CertFile           string `yaml:"cert_file"`
+            KeyFile            string `yaml:"key_file"`
+            InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+        } `yaml:"tls"`
     } `yaml:"kafka"`
+    Bus struct {
+        Driver     string `yaml:"driver"`      // kafka, memory or file
+        FilePath   string `yaml:"file_path"`   // NDJSON event log for the file driver; subscribers keep their offset next to it
+        MaxRetries int    `yaml:"max_retries"` // handler attempts per message
+    } `yaml:"bus"`
+    Outbox struct {
+        BatchSize           int `yaml:"batch_size"`
+        PollIntervalSeconds int `yaml:"poll_interval_seconds"`
+    } `yaml:"outbox"`
+    Frontier struct {
+        Driver   string `yaml:"driver"`    // memory, file or db
+        FilePath string `yaml:"file_path"` // JSON checkpoint for the file driver
+    } `yaml:"frontier"`
     Scraper struct {
         BaseURL        string `yaml:"base_url"`
         MaxDepth       int    `yaml:"max_depth"`
         DelaySeconds   int    `yaml:"delay_seconds"`
         UserAgent      string `yaml:"user_agent"`
+        Identities     []Identity `yaml:"identities"` // rotated; user_agent alone is used when empty
         OutputFormat   string `yaml:"output_format"` // "db" or "json"
         JSONOutputPath string `yaml:"json_output_path"`
+        Workers            int `yaml:"workers"`              // concurrent browser tabs or HTTP requests
+        PageTimeoutSeconds int `yaml:"page_timeout_seconds"` // per page, excluding rate limit waits
+        MaxPages           int `yaml:"max_pages"`            // listing pages per category or search
+        Mode            string `yaml:"mode"`             // api or browser
+        BrowserFallback bool   `yaml:"browser_fallback"` // retry with chromedp when the API fails
+        API             struct {
+            SearchURL      string            `yaml:"search_url"`
+            Params         map[string]string `yaml:"params"` // added to every request, e.g. culture or storefrontId
+            TimeoutSeconds int               `yaml:"timeout_seconds"`
+        } `yaml:"api"`
+        Profiles []SelectorProfile `yaml:"profiles"` // per storefront selectors, see profile.go
+        // delay_seconds is the steady interval between requests to one host
+        RateLimit struct {
+            Burst             int  `yaml:"burst"`         // requests allowed back to back
+            JitterMillis      int  `yaml:"jitter_millis"` // random extra wait added to every request
+            MaxBackoffSeconds int  `yaml:"max_backoff_seconds"`
+            RespectRobots     bool `yaml:"respect_robots"`
+        } `yaml:"rate_limit"`
+        // Retries per error class; attempts include the first try
+        Retry struct {
+            MaxDelaySeconds int       `yaml:"max_delay_seconds"`
+            NotFound        RetryRule `yaml:"not_found"`
+            Blocked         RetryRule `yaml:"blocked"`
+            LayoutChanged   RetryRule `yaml:"layout_changed"`
+            Timeout         RetryRule `yaml:"timeout"`
+            Other           RetryRule `yaml:"other"`
+        } `yaml:"retry"`
+        Proxies struct {
+            URLs                       []string `yaml:"urls" secret:"true"` // http://, https:// or socks5://
+            CooldownSeconds            int      `yaml:"cooldown_seconds"` // rest after a block
+            HealthCheckURL             string   `yaml:"health_check_url"`
+            HealthCheckIntervalSeconds int      `yaml:"health_check_interval_seconds"`
+        } `yaml:"proxies"`
+        // Gallery images are always recorded; downloading them is optional
+        Images struct {
+            Download       bool   `yaml:"download"`
+            Dir            string `yaml:"dir"` // files are named by content hash
+            TimeoutSeconds int    `yaml:"timeout_seconds"`
+        } `yaml:"images"`
     } `yaml:"scraper"`
 }
 
+// RetryRule is how often and how patiently one class of scraping errors is
+// retried. The delay doubles after every attempt.
+type RetryRule struct {
+    Attempts        int `yaml:"attempts"`
+    BaseDelayMillis int `yaml:"base_delay_millis"`
+}
+
+// Identity is a user agent and the headers a real browser with that user
+// agent would send, such as Accept-Language.
+type Identity struct {
+    UserAgent string            `yaml:"user_agent"`
+    Headers   map[string]string `yaml:"headers"`
+}
+
+// LoadConfig reads the YAML file at path and then applies environment
+// overrides, see applyEnv.
 func LoadConfig(path string) (*Config, error) {
     data, err := os.ReadFile(path)
     if err != nil {
@@ -40,5 +139,50 @@ func LoadConfig(path string) (*Config, error) {
         return nil, err
     }
 
+    if err := applyEnv(config, os.LookupEnv); err != nil {
+        return nil, err
+    }
+
     return config, nil
+}
+
+// DSN returns the Postgres connection string for the database section.
+func (c *Config) DSN() string {
+    db := c.Database
+    sslMode := db.SSLMode
+    if sslMode == "" {
+        sslMode = "disable"
+    }
+
+    params := []struct{ key, value string }{
+        {"host", db.Host},
+        {"port", strconv.Itoa(db.Port)},
+        {"user", db.User},
+        {"password", db.Password},
+        {"dbname", db.Name},
+        {"sslmode", sslMode},
+        {"sslrootcert", db.SSLRootCert},
+        {"sslcert", db.SSLCert},
+        {"sslkey", db.SSLKey},
+    }
+
+    var parts []string
+    for _, p := range params {
+        if p.value == "" {
+            continue
+        }
+        parts = append(parts, p.key+"="+quoteDSNValue(p.value))
+    }
+    return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes a libpq keyword/value so passwords containing spaces
+// or quotes survive.
+func quoteDSNValue(v string) string {
+    if !strings.ContainsAny(v, ` '\`) {
+        return v
+    }
+    v = strings.ReplaceAll(v, `\`, `\\`)
+    v = strings.ReplaceAll(v, `'`, `\'`)
+    return "'" + v + "'"
 }
\ No newline at end of file
@@ -0,0 +1,167 @@
+package config
+
+import (
+    "fmt"
+    "net/url"
+    "os"
+    "reflect"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to every environment override.
+const EnvPrefix = "SCRAPER"
+
+// applyEnv overrides config fields from the environment. Variable names are
+// the upper-cased yaml path, e.g. database.password is read from
+// SCRAPER_DATABASE_PASSWORD and kafka.sasl.username from
+// SCRAPER_KAFKA_SASL_USERNAME. If NAME is not set, NAME_FILE may point to a
+// file holding the value, which is how docker and Kubernetes mount secrets.
+// Lists of strings are comma separated and maps of strings are
+// comma separated key=value pairs; lists of objects such as
+// scraper.profiles take YAML or JSON, e.g. SCRAPER_SCRAPER_IDENTITIES=
+// '[{user_agent: "Mozilla/5.0 ..."}]'.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+    return walkFields(reflect.ValueOf(cfg).Elem(), EnvPrefix, func(name string, field reflect.Value, _ reflect.StructField) error {
+        value, ok, err := lookupEnv(name, lookup)
+        if err != nil || !ok {
+            return err
+        }
+        if err := setField(field, value); err != nil {
+            return fmt.Errorf("invalid value for %s: %w", name, err)
+        }
+        return nil
+    })
+}
+
+func lookupEnv(name string, lookup func(string) (string, bool)) (string, bool, error) {
+    if value, ok := lookup(name); ok {
+        return value, true, nil
+    }
+
+    path, ok := lookup(name + "_FILE")
+    if !ok {
+        return "", false, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return "", false, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+    }
+    return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+func setField(field reflect.Value, value string) error {
+    switch field.Kind() {
+    case reflect.String:
+        field.SetString(value)
+    case reflect.Int:
+        n, err := strconv.Atoi(strings.TrimSpace(value))
+        if err != nil {
+            return err
+        }
+        field.SetInt(int64(n))
+    case reflect.Bool:
+        b, err := strconv.ParseBool(strings.TrimSpace(value))
+        if err != nil {
+            return err
+        }
+        field.SetBool(b)
+    case reflect.Slice:
+        if field.Type().Elem().Kind() != reflect.String {
+            return setYAML(field, value)
+        }
+        field.Set(reflect.ValueOf(splitList(value)))
+    case reflect.Map:
+        if field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.String {
+            return setYAML(field, value)
+        }
+        m := make(map[string]string)
+        for _, pair := range splitList(value) {
+            k, v, ok := strings.Cut(pair, "=")
+            if !ok {
+                return fmt.Errorf("%q is not a key=value pair", pair)
+            }
+            m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+        }
+        field.Set(reflect.ValueOf(m))
+    default:
+        return fmt.Errorf("unsupported field type %s", field.Type())
+    }
+    return nil
+}
+
+func splitList(value string) []string {
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// setYAML decodes value, YAML or JSON, into a fresh value of the field's
+// type and replaces the field with it.
+func setYAML(field reflect.Value, value string) error {
+    ptr := reflect.New(field.Type())
+    if err := yaml.Unmarshal([]byte(value), ptr.Interface()); err != nil {
+        return err
+    }
+    field.Set(ptr.Elem())
+    return nil
+}
+
+// walkFields calls fn for every leaf field of v with its environment name.
+func walkFields(v reflect.Value, prefix string, fn func(name string, field reflect.Value, sf reflect.StructField) error) error {
+    t := v.Type()
+    for i := 0; i < t.NumField(); i++ {
+        sf := t.Field(i)
+        tag := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+        if tag == "" || tag == "-" {
+            continue
+        }
+
+        name := prefix + "_" + strings.ToUpper(tag)
+        field := v.Field(i)
+        if field.Kind() == reflect.Struct {
+            if err := walkFields(field, name, fn); err != nil {
+                return err
+            }
+            continue
+        }
+        if err := fn(name, field, sf); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Redacted returns a copy of the config with every field tagged
+// secret:"true" masked, for printing and logging.
+func (c *Config) Redacted() *Config {
+    out := *c
+    walkFields(reflect.ValueOf(&out).Elem(), EnvPrefix, func(_ string, field reflect.Value, sf reflect.StructField) error {
+        if sf.Tag.Get("secret") != "true" {
+            return nil
+        }
+        switch {
+        case field.Kind() == reflect.String && field.String() != "":
+            field.SetString("********")
+        case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+            // URL lists keep everything but their passwords; the copy
+            // must not share the original's backing array
+            items := make([]string, field.Len())
+            for i := range items {
+                items[i] = "********"
+                if u, err := url.Parse(field.Index(i).String()); err == nil {
+                    items[i] = u.Redacted()
+                }
+            }
+            field.Set(reflect.ValueOf(items))
+        }
+        return nil
+    })
+    return &out
+}
@@ -0,0 +1,40 @@
+package config
+
+// SelectorProfile holds the CSS selectors and number format for one
+// storefront. A profile applies to pages whose host is in Hosts and whose
+// path starts with PathPrefix; the most specific match wins.
+type SelectorProfile struct {
+    Name         string       `yaml:"name"`
+    Hosts        []string     `yaml:"hosts"`
+    PathPrefix   string       `yaml:"path_prefix"`
+    NumberFormat NumberFormat `yaml:"number_format"`
+    Selectors    Selectors    `yaml:"selectors"`
+}
+
+// NumberFormat describes how prices are written on the page, e.g.
+// "1.299,90 TL" or "1,299.90 AED".
+type NumberFormat struct {
+    DecimalSeparator   string   `yaml:"decimal_separator"`
+    ThousandsSeparator string   `yaml:"thousands_separator"`
+    CurrencySymbols    []string `yaml:"currency_symbols"`
+    Currency           string   `yaml:"currency"` // ISO code used when the page has no structured data
+}
+
+// Selectors left empty fall back to the built-in defaults.
+type Selectors struct {
+    ProductDetail string `yaml:"product_detail"` // waited for before a product page is read
+    ProductName   string `yaml:"product_name"`
+    Brand         string `yaml:"brand"`
+    Price         string `yaml:"price"`
+    OriginalPrice string `yaml:"original_price"`
+    Rating        string `yaml:"rating"`
+    Images        string `yaml:"images"`
+    Description   string `yaml:"description"`
+    Variants      string `yaml:"variants"`
+    VariantSoldOut string `yaml:"variant_sold_out"` // variant items that cannot be bought
+    ProductLinks  string `yaml:"product_links"`
+    ResultCount   string `yaml:"result_count"` // text with the number of products in a listing
+    Navigation    string `yaml:"navigation"` // waited for before top-level categories are read
+    TopCategories string `yaml:"top_categories"`
+    Subcategories string `yaml:"subcategories"`
+}
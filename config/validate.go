@@ -0,0 +1,254 @@
+package config
+
+import (
+    "fmt"
+    "net"
+    "net/url"
+    "strconv"
+    "strings"
+)
+
+// FieldError describes one invalid config value by its yaml path.
+type FieldError struct {
+    Field   string
+    Message string
+}
+
+func (e FieldError) Error() string {
+    return e.Field + ": " + e.Message
+}
+
+// ValidationError collects every problem found by Validate so they can be
+// fixed in one go.
+type ValidationError struct {
+    Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+    lines := make([]string, len(e.Errors))
+    for i, fe := range e.Errors {
+        lines[i] = "  - " + fe.Error()
+    }
+    return fmt.Sprintf("invalid configuration (%d problems):\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
+// ApplyDefaults fills in zero values with sensible defaults. It runs after
+// the file, environment and flags have been applied.
+func (c *Config) ApplyDefaults() {
+    setDefault(&c.App.ShutdownTimeoutSeconds, 30)
+
+    setDefault(&c.Database.Host, "localhost")
+    setDefault(&c.Database.Port, 5432)
+    setDefault(&c.Database.SSLMode, "disable")
+
+    setDefault(&c.Kafka.Topic, "price-drops")
+    setDefault(&c.Kafka.GroupID, "scraper-group")
+    setDefault(&c.Kafka.ClientID, "trendyol-scraper")
+    setDefault(&c.Kafka.Compression, "none")
+    setDefault(&c.Kafka.Partitioner, "hash")
+    setDefault(&c.Kafka.RebalanceStrategy, "range")
+    setDefault(&c.Kafka.InitialOffset, "oldest")
+    if c.Kafka.SASL.Enabled {
+        setDefault(&c.Kafka.SASL.Mechanism, "PLAIN")
+    }
+
+    setDefault(&c.Bus.Driver, "kafka")
+    setDefault(&c.Bus.FilePath, "./output/events.ndjson")
+    setDefault(&c.Bus.MaxRetries, 3)
+
+    setDefault(&c.Outbox.BatchSize, 100)
+    setDefault(&c.Outbox.PollIntervalSeconds, 5)
+
+    setDefault(&c.Frontier.Driver, "memory")
+    setDefault(&c.Frontier.FilePath, "./output/frontier.json")
+
+    setDefault(&c.Scraper.BaseURL, "https://www.trendyol.com")
+    setDefault(&c.Scraper.MaxDepth, 3)
+    setDefault(&c.Scraper.DelaySeconds, 2)
+    setDefault(&c.Scraper.OutputFormat, "json")
+    setDefault(&c.Scraper.JSONOutputPath, "./output")
+    setDefault(&c.Scraper.Mode, "api")
+    setDefault(&c.Scraper.Workers, 4)
+    setDefault(&c.Scraper.PageTimeoutSeconds, 60)
+    setDefault(&c.Scraper.MaxPages, 100)
+    setDefault(&c.Scraper.RateLimit.Burst, 1)
+    setDefault(&c.Scraper.RateLimit.MaxBackoffSeconds, 300)
+    setDefault(&c.Scraper.API.SearchURL, "https://apigw.trendyol.com/discovery-sfint-search-service/api/search/products")
+    setDefault(&c.Scraper.API.TimeoutSeconds, 30)
+    setDefault(&c.Scraper.Proxies.CooldownSeconds, 300)
+    setDefault(&c.Scraper.Proxies.HealthCheckURL, c.Scraper.BaseURL)
+    setDefault(&c.Scraper.Proxies.HealthCheckIntervalSeconds, 60)
+    setDefault(&c.Scraper.Images.Dir, "./output/images")
+    setDefault(&c.Scraper.Images.TimeoutSeconds, 30)
+    setDefault(&c.Scraper.Retry.MaxDelaySeconds, 120)
+    setDefault(&c.Scraper.Retry.NotFound, RetryRule{Attempts: 1})
+    setDefault(&c.Scraper.Retry.Blocked, RetryRule{Attempts: 3, BaseDelayMillis: 30000})
+    setDefault(&c.Scraper.Retry.LayoutChanged, RetryRule{Attempts: 1})
+    setDefault(&c.Scraper.Retry.Timeout, RetryRule{Attempts: 3, BaseDelayMillis: 2000})
+    setDefault(&c.Scraper.Retry.Other, RetryRule{Attempts: 2, BaseDelayMillis: 1000})
+}
+
+func setDefault[T comparable](field *T, value T) {
+    var zero T
+    if *field == zero {
+        *field = value
+    }
+}
+
+// Validate checks ranges and enumerated values and reports every problem at
+// once. Call ApplyDefaults first so unset values are not reported.
+func (c *Config) Validate() error {
+    v := &validator{}
+
+    v.check(c.App.ShutdownTimeoutSeconds > 0, "app.shutdown_timeout_seconds", "must be greater than 0")
+
+    v.check(c.Database.Host != "", "database.host", "is required")
+    v.check(c.Database.Port > 0 && c.Database.Port <= 65535, "database.port", "must be between 1 and 65535, got %d", c.Database.Port)
+    v.check(c.Database.User != "", "database.user", "is required (set it in the file or SCRAPER_DATABASE_USER)")
+    v.check(c.Database.Name != "", "database.name", "is required")
+    v.oneOf("database.sslmode", c.Database.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+
+    v.oneOf("bus.driver", c.Bus.Driver, "kafka", "memory", "file")
+    v.check(c.Bus.MaxRetries >= 1, "bus.max_retries", "must be at least 1, got %d", c.Bus.MaxRetries)
+    if c.Bus.Driver == "file" {
+        v.check(c.Bus.FilePath != "", "bus.file_path", "is required for the file driver")
+    }
+    if c.Bus.Driver == "kafka" {
+        c.validateKafka(v)
+    }
+
+    v.check(c.Outbox.BatchSize > 0, "outbox.batch_size", "must be greater than 0, got %d", c.Outbox.BatchSize)
+    v.check(c.Outbox.PollIntervalSeconds > 0, "outbox.poll_interval_seconds", "must be greater than 0, got %d", c.Outbox.PollIntervalSeconds)
+
+    v.oneOf("frontier.driver", c.Frontier.Driver, "memory", "file", "db")
+    if c.Frontier.Driver == "file" {
+        v.check(c.Frontier.FilePath != "", "frontier.file_path", "is required for the file driver")
+    }
+
+    if u, err := url.Parse(c.Scraper.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        v.add("scraper.base_url", "must be an absolute http(s) URL, got %q", c.Scraper.BaseURL)
+    }
+    v.check(c.Scraper.MaxDepth >= 1 && c.Scraper.MaxDepth <= 10, "scraper.max_depth", "must be between 1 and 10, got %d", c.Scraper.MaxDepth)
+    v.check(c.Scraper.DelaySeconds >= 1, "scraper.delay_seconds", "must be at least 1 to avoid hammering the site, got %d", c.Scraper.DelaySeconds)
+    v.check(c.Scraper.Workers >= 1 && c.Scraper.Workers <= 32, "scraper.workers", "must be between 1 and 32, got %d", c.Scraper.Workers)
+    v.check(c.Scraper.PageTimeoutSeconds > 0, "scraper.page_timeout_seconds", "must be greater than 0, got %d", c.Scraper.PageTimeoutSeconds)
+    v.check(c.Scraper.MaxPages >= 1, "scraper.max_pages", "must be at least 1, got %d", c.Scraper.MaxPages)
+    v.check(c.Scraper.RateLimit.Burst >= 1, "scraper.rate_limit.burst", "must be at least 1, got %d", c.Scraper.RateLimit.Burst)
+    v.check(c.Scraper.RateLimit.JitterMillis >= 0, "scraper.rate_limit.jitter_millis", "must not be negative, got %d", c.Scraper.RateLimit.JitterMillis)
+    v.check(c.Scraper.RateLimit.MaxBackoffSeconds >= 1, "scraper.rate_limit.max_backoff_seconds", "must be at least 1, got %d", c.Scraper.RateLimit.MaxBackoffSeconds)
+    v.check(c.Scraper.Retry.MaxDelaySeconds >= 1, "scraper.retry.max_delay_seconds", "must be at least 1, got %d", c.Scraper.Retry.MaxDelaySeconds)
+    for _, r := range []struct {
+        class string
+        rule  RetryRule
+    }{
+        {"not_found", c.Scraper.Retry.NotFound},
+        {"blocked", c.Scraper.Retry.Blocked},
+        {"layout_changed", c.Scraper.Retry.LayoutChanged},
+        {"timeout", c.Scraper.Retry.Timeout},
+        {"other", c.Scraper.Retry.Other},
+    } {
+        field, rule := "scraper.retry."+r.class, r.rule
+        v.check(rule.Attempts >= 1, field+".attempts", "must be at least 1, got %d", rule.Attempts)
+        v.check(rule.BaseDelayMillis >= 0, field+".base_delay_millis", "must not be negative, got %d", rule.BaseDelayMillis)
+    }
+    for i, identity := range c.Scraper.Identities {
+        v.check(identity.UserAgent != "", fmt.Sprintf("scraper.identities[%d].user_agent", i), "is required")
+    }
+    for i, raw := range c.Scraper.Proxies.URLs {
+        if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+            v.add(fmt.Sprintf("scraper.proxies.urls[%d]", i), "must be an http, https or socks5 URL, got %q", raw)
+        }
+    }
+    if len(c.Scraper.Proxies.URLs) > 0 {
+        v.check(c.Scraper.Proxies.CooldownSeconds >= 1, "scraper.proxies.cooldown_seconds", "must be at least 1, got %d", c.Scraper.Proxies.CooldownSeconds)
+        v.check(c.Scraper.Proxies.HealthCheckIntervalSeconds >= 1, "scraper.proxies.health_check_interval_seconds", "must be at least 1, got %d", c.Scraper.Proxies.HealthCheckIntervalSeconds)
+        if u, err := url.Parse(c.Scraper.Proxies.HealthCheckURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            v.add("scraper.proxies.health_check_url", "must be an absolute http(s) URL, got %q", c.Scraper.Proxies.HealthCheckURL)
+        }
+    }
+    if c.Scraper.Images.Download {
+        v.check(c.Scraper.Images.Dir != "", "scraper.images.dir", "is required when images.download is enabled")
+        v.check(c.Scraper.Images.TimeoutSeconds >= 1, "scraper.images.timeout_seconds", "must be at least 1, got %d", c.Scraper.Images.TimeoutSeconds)
+    }
+    v.oneOf("scraper.output_format", c.Scraper.OutputFormat, "db", "json")
+    if c.Scraper.OutputFormat == "json" {
+        v.check(c.Scraper.JSONOutputPath != "", "scraper.json_output_path", "is required when output_format is json")
+    }
+
+    v.oneOf("scraper.mode", c.Scraper.Mode, "api", "browser")
+    if c.Scraper.Mode == "api" {
+        if u, err := url.Parse(c.Scraper.API.SearchURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            v.add("scraper.api.search_url", "must be an absolute http(s) URL, got %q", c.Scraper.API.SearchURL)
+        }
+        v.check(c.Scraper.API.TimeoutSeconds > 0, "scraper.api.timeout_seconds", "must be greater than 0, got %d", c.Scraper.API.TimeoutSeconds)
+    }
+
+    for i, p := range c.Scraper.Profiles {
+        field := fmt.Sprintf("scraper.profiles[%d]", i)
+        v.check(p.Name != "", field+".name", "is required")
+        v.check(len(p.Hosts) > 0, field+".hosts", "at least one host is required")
+        nf := p.NumberFormat
+        v.check(len(nf.DecimalSeparator) <= 1, field+".number_format.decimal_separator", "must be a single character, got %q", nf.DecimalSeparator)
+        v.check(nf.DecimalSeparator == "" || nf.DecimalSeparator != nf.ThousandsSeparator, field+".number_format", "decimal and thousands separators must differ")
+    }
+
+    if len(v.errs) > 0 {
+        return &ValidationError{Errors: v.errs}
+    }
+    return nil
+}
+
+func (c *Config) validateKafka(v *validator) {
+    kc := c.Kafka
+
+    v.check(len(kc.Brokers) > 0, "kafka.brokers", "at least one broker is required when bus.driver is kafka")
+    for i, broker := range kc.Brokers {
+        host, port, err := net.SplitHostPort(broker)
+        if err != nil || host == "" {
+            v.add(fmt.Sprintf("kafka.brokers[%d]", i), "must be host:port, got %q", broker)
+            continue
+        }
+        if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+            v.add(fmt.Sprintf("kafka.brokers[%d]", i), "has an invalid port %q", port)
+        }
+    }
+    v.check(kc.Topic != "", "kafka.topic", "is required")
+    v.check(kc.GroupID != "", "kafka.group_id", "is required")
+    v.check(kc.DeadLetterTopic == "" || kc.DeadLetterTopic != kc.Topic, "kafka.dead_letter_topic", "must differ from kafka.topic")
+    v.oneOf("kafka.compression", strings.ToLower(kc.Compression), "none", "gzip", "snappy", "lz4", "zstd")
+    v.oneOf("kafka.partitioner", strings.ToLower(kc.Partitioner), "hash", "random", "roundrobin")
+    v.oneOf("kafka.rebalance_strategy", strings.ToLower(kc.RebalanceStrategy), "range", "roundrobin", "sticky")
+    v.oneOf("kafka.initial_offset", strings.ToLower(kc.InitialOffset), "oldest", "newest")
+
+    if kc.SASL.Enabled {
+        v.oneOf("kafka.sasl.mechanism", strings.ToUpper(kc.SASL.Mechanism), "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512")
+        v.check(kc.SASL.Username != "", "kafka.sasl.username", "is required when SASL is enabled")
+        v.check(kc.SASL.Password != "", "kafka.sasl.password", "is required when SASL is enabled (or set SCRAPER_KAFKA_SASL_PASSWORD_FILE)")
+    }
+    if kc.TLS.Enabled {
+        v.check((kc.TLS.CertFile == "") == (kc.TLS.KeyFile == ""), "kafka.tls", "cert_file and key_file must be set together")
+    }
+}
+
+type validator struct {
+    errs []FieldError
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+    v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) check(ok bool, field, format string, args ...interface{}) {
+    if !ok {
+        v.add(field, format, args...)
+    }
+}
+
+func (v *validator) oneOf(field, value string, allowed ...string) {
+    for _, a := range allowed {
+        if value == a {
+            return
+        }
+    }
+    v.add(field, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
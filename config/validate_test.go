@@ -0,0 +1,111 @@
+package config
+
+import (
+    "errors"
+    "strings"
+    "testing"
+)
+
+func validConfig() *Config {
+    cfg := &Config{}
+    cfg.Database.User = "postgres"
+    cfg.Database.Name = "trendyol_scraper"
+    cfg.Kafka.Brokers = []string{"localhost:9092"}
+    cfg.ApplyDefaults()
+    return cfg
+}
+
+func TestValidateDefaults(t *testing.T) {
+    if err := validConfig().Validate(); err != nil {
+        t.Errorf("defaults are invalid: %v", err)
+    }
+}
+
+func TestValidate(t *testing.T) {
+    tests := []struct {
+        name   string
+        modify func(*Config)
+        want   []string // fields reported, in order
+    }{
+        {
+            name:   "missing required",
+            modify: func(c *Config) { c.Database.User = ""; c.Database.Name = "" },
+            want:   []string{"database.user", "database.name"},
+        },
+        {
+            name:   "out of range",
+            modify: func(c *Config) { c.Database.Port = 70000; c.Scraper.Workers = 0; c.Scraper.MaxDepth = 11 },
+            want:   []string{"database.port", "scraper.max_depth", "scraper.workers"},
+        },
+        {
+            name:   "enums",
+            modify: func(c *Config) { c.Bus.Driver = "redis"; c.Scraper.Mode = "headless" },
+            want:   []string{"bus.driver", "scraper.mode"},
+        },
+        {
+            name:   "kafka only for the kafka driver",
+            modify: func(c *Config) { c.Bus.Driver = "memory"; c.Kafka.Topic = "" },
+        },
+        {
+            name:   "dead-letter topic",
+            modify: func(c *Config) { c.Kafka.DeadLetterTopic = c.Kafka.Topic },
+            want:   []string{"kafka.dead_letter_topic"},
+        },
+        {
+            name: "indexed list entries",
+            modify: func(c *Config) {
+                c.Scraper.Identities = []Identity{{UserAgent: "UA"}, {}}
+                c.Scraper.Proxies.URLs = []string{"ftp://proxy:21"}
+                c.Scraper.Profiles = []SelectorProfile{{Name: "tr", Hosts: []string{"trendyol.com"}, NumberFormat: NumberFormat{DecimalSeparator: ",", ThousandsSeparator: ","}}}
+            },
+            want: []string{"scraper.identities[1].user_agent", "scraper.proxies.urls[0]", "scraper.profiles[0].number_format"},
+        },
+        {
+            name:   "retry rules",
+            modify: func(c *Config) { c.Scraper.Retry.Blocked.Attempts = 0; c.Scraper.Retry.Other.BaseDelayMillis = -1 },
+            want:   []string{"scraper.retry.blocked.attempts", "scraper.retry.other.base_delay_millis"},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            cfg := validConfig()
+            tt.modify(cfg)
+            err := cfg.Validate()
+            if len(tt.want) == 0 {
+                if err != nil {
+                    t.Errorf("Validate() = %v, want nil", err)
+                }
+                return
+            }
+
+            var verr *ValidationError
+            if !errors.As(err, &verr) {
+                t.Fatalf("Validate() = %v, want a *ValidationError", err)
+            }
+            var got []string
+            for _, fe := range verr.Errors {
+                got = append(got, fe.Field)
+            }
+            if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+                t.Errorf("reported fields = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+    cfg := validConfig()
+    cfg.Database.Port = 0
+    cfg.Bus.MaxRetries = -1
+
+    err := cfg.Validate()
+    if err == nil {
+        t.Fatal("Validate() = nil, want two problems")
+    }
+    want := "invalid configuration (2 problems):\n" +
+        "  - database.port: must be between 1 and 65535, got 0\n" +
+        "  - bus.max_retries: must be at least 1, got -1"
+    if err.Error() != want {
+        t.Errorf("message =\n%s\nwant\n%s", err, want)
+    }
+}
@@ -0,0 +1,187 @@
+package frontier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"trendyol-scraper/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DB keeps the frontier in the frontier_entries table. Entries are claimed
+// with SKIP LOCKED, so several scrapers can share one crawl.
+type DB struct {
+	db          *gorm.DB
+	maxAttempts int
+}
+
+func NewDB(db *gorm.DB, maxAttempts int) *DB {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &DB{db: db, maxAttempts: maxAttempts}
+}
+
+var scopeURL = []clause.Column{{Name: "scope"}, {Name: "url"}}
+
+func (d *DB) Add(ctx context.Context, scope string, depth int, urls ...string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	rows := make([]models.FrontierEntry, len(urls))
+	for i, url := range urls {
+		rows[i] = models.FrontierEntry{Scope: scope, URL: url, Depth: depth, Status: string(Queued)}
+	}
+	err := d.db.WithContext(ctx).Clauses(clause.OnConflict{Columns: scopeURL, DoNothing: true}).Create(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to add %d URLs to the frontier: %w", len(urls), err)
+	}
+	return nil
+}
+
+func (d *DB) Next(ctx context.Context, scope string, n int) ([]Entry, error) {
+	var rows []models.FrontierEntry
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("scope = ? AND status = ?", scope, string(Queued)).
+			Order("id").
+			Limit(n).
+			Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(rows))
+		for i := range rows {
+			ids[i] = rows[i].ID
+			rows[i].Status = string(InProgress)
+		}
+		return tx.Model(&models.FrontierEntry{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":     string(InProgress),
+			"updated_at": time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim frontier entries: %w", err)
+	}
+
+	entries := make([]Entry, len(rows))
+	for i, row := range rows {
+		entries[i] = fromRow(row)
+	}
+	return entries, nil
+}
+
+func (d *DB) Done(ctx context.Context, scope, url string, data any) error {
+	raw, err := encode(data)
+	if err != nil {
+		return err
+	}
+	var stored *string
+	if raw != nil {
+		s := string(raw)
+		stored = &s
+	}
+
+	row := models.FrontierEntry{Scope: scope, URL: url, Status: string(Done), Attempts: 1, Data: stored}
+	err = d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: scopeURL,
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"status":     string(Done),
+			"attempts":   gorm.Expr("frontier_entries.attempts + 1"),
+			"last_error": "",
+			"data":       stored,
+			"updated_at": time.Now().UTC(),
+		}),
+	}).Create(&row).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark %s done: %w", url, err)
+	}
+	return nil
+}
+
+func (d *DB) Fail(ctx context.Context, scope, url string, cause error) error {
+	row := models.FrontierEntry{
+		Scope:     scope,
+		URL:       url,
+		Status:    string(failedStatus(1, d.maxAttempts, cause)),
+		Attempts:  1,
+		LastError: cause.Error(),
+	}
+
+	// Permanent failures use up the remaining attempts
+	maxAttempts := d.maxAttempts
+	if row.Status == string(Failed) {
+		maxAttempts = 0
+	}
+	err := d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: scopeURL,
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"status": gorm.Expr("CASE WHEN frontier_entries.attempts + 1 >= ? THEN ? ELSE ? END",
+				maxAttempts, string(Failed), string(Queued)),
+			"attempts":   gorm.Expr("frontier_entries.attempts + 1"),
+			"last_error": cause.Error(),
+			"updated_at": time.Now().UTC(),
+		}),
+	}).Create(&row).Error
+	if err != nil {
+		return fmt.Errorf("failed to record failure of %s: %w", url, err)
+	}
+	return nil
+}
+
+func (d *DB) Entries(ctx context.Context, scope string) ([]Entry, error) {
+	var rows []models.FrontierEntry
+	if err := d.db.WithContext(ctx).Where("scope = ?", scope).Order("id").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load frontier entries: %w", err)
+	}
+
+	entries := make([]Entry, len(rows))
+	for i, row := range rows {
+		entries[i] = fromRow(row)
+	}
+	return entries, nil
+}
+
+func (d *DB) Requeue(ctx context.Context, scope string) (int, error) {
+	result := d.db.WithContext(ctx).Model(&models.FrontierEntry{}).
+		Where("scope = ? AND status IN ?", scope, []string{string(InProgress), string(Failed)}).
+		Updates(map[string]interface{}{
+			"status":     string(Queued),
+			"attempts":   gorm.Expr("CASE WHEN status = ? THEN 0 ELSE attempts END", string(Failed)),
+			"updated_at": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to requeue frontier entries: %w", result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+func (d *DB) Clear(ctx context.Context, scope string) error {
+	if err := d.db.WithContext(ctx).Where("scope = ?", scope).Delete(&models.FrontierEntry{}).Error; err != nil {
+		return fmt.Errorf("failed to clear frontier scope %s: %w", scope, err)
+	}
+	return nil
+}
+
+func fromRow(row models.FrontierEntry) Entry {
+	e := Entry{
+		Scope:     row.Scope,
+		URL:       row.URL,
+		Depth:     row.Depth,
+		Status:    Status(row.Status),
+		Attempts:  row.Attempts,
+		LastError: row.LastError,
+		UpdatedAt: row.UpdatedAt,
+	}
+	if row.Data != nil {
+		e.Data = json.RawMessage(*row.Data)
+	}
+	return e
+}
@@ -0,0 +1,168 @@
+package frontier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// File is a Memory frontier that keeps a log of its changes in a file, so
+// crawls resume across runs without a database. Every change appends only
+// the entries it touched; the log is rewritten to the current state when
+// it is loaded and once it holds twice as many entries as that, so the
+// writing stays linear in the number of changes.
+type File struct {
+	*Memory
+	path   string
+	logged int // entries in the log since it was last rewritten
+}
+
+// fileRecord is one record of the log: the new state of entries of Scope,
+// or Cleared when the scope was forgotten.
+type fileRecord struct {
+	Scope   string  `json:"scope"`
+	Entries []Entry `json:"entries,omitempty"`
+	Cleared bool    `json:"cleared,omitempty"`
+}
+
+// compactSlack keeps small logs from being rewritten on every change.
+const compactSlack = 64
+
+// NewFile loads the frontier saved at path, if there is one.
+func NewFile(path string, maxAttempts int) (*File, error) {
+	f := &File{Memory: NewMemory(maxAttempts), path: path}
+
+	file, err := os.Open(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read frontier file: %w", err)
+	default:
+		err := f.load(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if err := f.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	f.changed = f.append
+	return f, nil
+}
+
+// load replays the log. A record cut short by a crash is dropped, as if
+// its change had not been made.
+func (f *File) load(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var rec fileRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("Dropping the incomplete last record of frontier file %s", f.path)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode frontier file %s: %w", f.path, err)
+		}
+
+		if rec.Cleared {
+			delete(f.scopes, rec.Scope)
+			continue
+		}
+		for _, e := range rec.Entries {
+			*f.entry(rec.Scope, e.URL, e.Status) = e
+		}
+	}
+}
+
+// append adds the change to the log, rewriting the log when it has grown
+// to twice the size of the state it describes.
+func (f *File) append(scope string, entries []*Entry) error {
+	rec := fileRecord{Scope: scope}
+	if _, ok := f.scopes[scope]; !ok {
+		rec.Cleared = true
+	}
+	for _, e := range entries {
+		rec.Entries = append(rec.Entries, *e)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode frontier: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create frontier directory: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write frontier file: %w", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write frontier file: %w", err)
+	}
+
+	f.logged += max(len(rec.Entries), 1)
+	if f.logged > 2*f.size()+compactSlack {
+		return f.compact()
+	}
+	return nil
+}
+
+// size is the number of entries in every scope.
+func (f *File) size() int {
+	n := 0
+	for _, s := range f.scopes {
+		n += len(s.Entries)
+	}
+	return n
+}
+
+// compact replaces the log with one record per scope. The file is
+// replaced atomically so a crash never leaves it truncated.
+func (f *File) compact() error {
+	names := make([]string, 0, len(f.scopes))
+	for name := range f.scopes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var data []byte
+	for _, name := range names {
+		rec := fileRecord{Scope: name}
+		for _, e := range f.scopes[name].Entries {
+			rec.Entries = append(rec.Entries, *e)
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to encode frontier: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create frontier directory: %w", err)
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write frontier file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+	f.logged = f.size()
+	return nil
+}
@@ -0,0 +1,86 @@
+package frontier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status is where an entry is in its crawl.
+type Status string
+
+const (
+	Queued     Status = "queued"
+	InProgress Status = "in_progress"
+	Done       Status = "done"
+	Failed     Status = "failed" // out of attempts until the next Requeue
+)
+
+// Entry is one URL of a crawl. Data holds the JSON result recorded by Done,
+// so a resumed crawl can reuse it instead of fetching the page again.
+type Entry struct {
+	Scope     string          `json:"scope"`
+	URL       string          `json:"url"`
+	Depth     int             `json:"depth"`
+	Status    Status          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Frontier keeps the URLs of crawls between runs. A scope names one crawl,
+// e.g. a category listing; URLs are unique within a scope and handed out in
+// the order they were added.
+type Frontier interface {
+	// Add queues urls, leaving URLs the scope already knows untouched.
+	Add(ctx context.Context, scope string, depth int, urls ...string) error
+	// Next marks up to n queued entries in progress and returns them.
+	Next(ctx context.Context, scope string, n int) ([]Entry, error)
+	// Done records a successful attempt and its result.
+	Done(ctx context.Context, scope, url string, data any) error
+	// Fail records a failed attempt. The entry is queued again until it
+	// runs out of attempts, and then marked failed; see Permanent.
+	Fail(ctx context.Context, scope, url string, cause error) error
+	// Entries returns every entry of scope in the order it was added.
+	Entries(ctx context.Context, scope string) ([]Entry, error)
+	// Requeue queues the entries an earlier run left in progress and gives
+	// failed entries a fresh set of attempts.
+	Requeue(ctx context.Context, scope string) (int, error)
+	// Clear forgets scope, so the next crawl of it starts over.
+	Clear(ctx context.Context, scope string) error
+}
+
+// Permanent marks a failure that retrying will not fix, such as a page
+// that no longer exists. Fail marks such entries failed at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// failedStatus is the status after attempts failed attempts, the last of
+// which failed with cause.
+func failedStatus(attempts, maxAttempts int, cause error) Status {
+	var permanent permanentError
+	if attempts >= maxAttempts || errors.As(cause, &permanent) {
+		return Failed
+	}
+	return Queued
+}
+
+func encode(data any) (json.RawMessage, error) {
+	if data == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode frontier data: %w", err)
+	}
+	return raw, nil
+}
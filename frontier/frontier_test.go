@@ -0,0 +1,302 @@
+package frontier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// frontiers returns one of each in-process implementation with maxAttempts.
+func frontiers(t *testing.T, maxAttempts int) map[string]Frontier {
+	file, err := NewFile(filepath.Join(t.TempDir(), "frontier.json"), maxAttempts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Frontier{"memory": NewMemory(maxAttempts), "file": file}
+}
+
+func statuses(t *testing.T, f Frontier, scope string) string {
+	t.Helper()
+	entries, err := f.Entries(context.Background(), scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []string
+	for _, e := range entries {
+		parts = append(parts, e.URL+"="+string(e.Status))
+	}
+	return strings.Join(parts, " ")
+}
+
+func urls(entries []Entry) string {
+	var parts []string
+	for _, e := range entries {
+		parts = append(parts, e.URL)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestFrontierLifecycle(t *testing.T) {
+	ctx := context.Background()
+	for name, f := range frontiers(t, 2) {
+		t.Run(name, func(t *testing.T) {
+			if err := f.Add(ctx, "cat", 1, "a", "b", "c", "d"); err != nil {
+				t.Fatal(err)
+			}
+			// Known URLs keep their state, new ones are appended
+			if err := f.Add(ctx, "cat", 2, "b", "e"); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Add(ctx, "other", 1, "a"); err != nil {
+				t.Fatal(err)
+			}
+
+			batch, err := f.Next(ctx, "cat", 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := urls(batch); got != "a b c" {
+				t.Fatalf("Next = %q, want the first three in order", got)
+			}
+			if batch[1].Depth != 1 || batch[1].Status != InProgress {
+				t.Errorf("b = %+v, want depth 1 in progress", batch[1])
+			}
+
+			if err := f.Done(ctx, "cat", "a", map[string]int{"products": 7}); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Fail(ctx, "cat", "b", errors.New("timeout")); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Fail(ctx, "cat", "c", Permanent(errors.New("gone"))); err != nil {
+				t.Fatal(err)
+			}
+			want := "a=done b=queued c=failed d=queued e=queued"
+			if got := statuses(t, f, "cat"); got != want {
+				t.Errorf("after one attempt = %q, want %q", got, want)
+			}
+			if got := statuses(t, f, "other"); got != "a=queued" {
+				t.Errorf("other scope = %q, want it untouched", got)
+			}
+
+			// b is handed out again and fails its last attempt
+			batch, err = f.Next(ctx, "cat", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := urls(batch); got != "b d e" {
+				t.Fatalf("second Next = %q, want b d e", got)
+			}
+			if err := f.Fail(ctx, "cat", "b", errors.New("timeout again")); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := f.Entries(ctx, "cat")
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, b := entries[0], entries[1]
+			if string(a.Data) != `{"products":7}` || a.Attempts != 1 {
+				t.Errorf("a = %+v, want its data and one attempt", a)
+			}
+			if b.Status != Failed || b.Attempts != 2 || b.LastError != "timeout again" {
+				t.Errorf("b = %+v, want failed after 2 attempts with the last error", b)
+			}
+			if entries[4].Depth != 2 {
+				t.Errorf("e depth = %d, want 2", entries[4].Depth)
+			}
+
+			// A new run queues what the last one left in progress and
+			// retries failed entries; done stays done
+			n, err := f.Requeue(ctx, "cat")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 4 {
+				t.Errorf("Requeue = %d, want 4 (b, c failed; d, e in progress)", n)
+			}
+			want = "a=done b=queued c=queued d=queued e=queued"
+			if got := statuses(t, f, "cat"); got != want {
+				t.Errorf("after Requeue = %q, want %q", got, want)
+			}
+			if entries, _ := f.Entries(ctx, "cat"); entries[1].Attempts != 0 {
+				t.Errorf("requeued b has %d attempts, want a fresh set", entries[1].Attempts)
+			}
+
+			if err := f.Clear(ctx, "cat"); err != nil {
+				t.Fatal(err)
+			}
+			if got := statuses(t, f, "cat"); got != "" {
+				t.Errorf("after Clear = %q, want nothing", got)
+			}
+			if n, err := f.Requeue(ctx, "unknown"); n != 0 || err != nil {
+				t.Errorf("Requeue of an unknown scope = %d, %v", n, err)
+			}
+		})
+	}
+}
+
+func TestFrontierSingleAttempt(t *testing.T) {
+	ctx := context.Background()
+	for name, f := range frontiers(t, 0) { // below 1 means one attempt
+		t.Run(name, func(t *testing.T) {
+			f.Add(ctx, "s", 0, "u")
+			f.Next(ctx, "s", 1)
+			if err := f.Fail(ctx, "s", "u", errors.New("blocked")); err != nil {
+				t.Fatal(err)
+			}
+			if got := statuses(t, f, "s"); got != "u=failed" {
+				t.Errorf("status = %q, want failed for the next run to requeue", got)
+			}
+			if batch, _ := f.Next(ctx, "s", 1); len(batch) != 0 {
+				t.Errorf("Next = %v, want nothing until Requeue", batch)
+			}
+		})
+	}
+}
+
+func TestFileResume(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested", "frontier.json")
+
+	first, err := NewFile(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Add(ctx, "cat", 1, "a", "b", "c")
+	first.Next(ctx, "cat", 2)
+	first.Done(ctx, "cat", "a", []int{1, 2})
+	// The run stops here with b in progress
+
+	resumed, err := NewFile(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses(t, resumed, "cat"); got != "a=done b=in_progress c=queued" {
+		t.Fatalf("reloaded = %q", got)
+	}
+	if n, err := resumed.Requeue(ctx, "cat"); n != 1 || err != nil {
+		t.Errorf("Requeue = %d, %v, want b queued again", n, err)
+	}
+	// The URL index is rebuilt, so known URLs are not added twice
+	resumed.Add(ctx, "cat", 1, "a", "d")
+	if got := statuses(t, resumed, "cat"); got != "a=done b=queued c=queued d=queued" {
+		t.Errorf("after resume = %q", got)
+	}
+	batch, err := resumed.Next(ctx, "cat", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := urls(batch); got != "b c d" {
+		t.Errorf("Next after resume = %q, want b c d", got)
+	}
+	entries, _ := resumed.Entries(ctx, "cat")
+	if string(entries[0].Data) != "[1,2]" {
+		t.Errorf("a data = %s, want the result saved by the first run", entries[0].Data)
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestFileCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "frontier.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFile(path, 1); err == nil {
+		t.Error("NewFile of a corrupt file = nil error, want a decode error")
+	}
+}
+
+func TestFileAppendsChanges(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "frontier.json")
+	f, err := NewFile(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 200
+	payload := strings.Repeat("x", 1000)
+	var all []string
+	for i := 0; i < n; i++ {
+		all = append(all, fmt.Sprintf("u%d", i))
+	}
+	f.Add(ctx, "cat", 0, all...)
+	f.Next(ctx, "cat", n)
+	// A Done appends its entry to the log; rewriting every payload on each
+	// one would write about n²/2 of them
+	rewrites := 0
+	for _, url := range all {
+		before, _ := os.ReadFile(path)
+		if err := f.Done(ctx, "cat", url, payload); err != nil {
+			t.Fatal(err)
+		}
+		after, _ := os.ReadFile(path)
+		if !bytes.HasPrefix(after, before) || len(after)-len(before) > 2*len(payload) {
+			rewrites++
+		}
+	}
+	if rewrites > 2 {
+		t.Errorf("log rewritten %d times for %d results, want it appended to", rewrites, n)
+	}
+
+	reloaded, err := NewFile(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := reloaded.Entries(ctx, "cat")
+	if len(entries) != n || entries[n-1].Status != Done || string(entries[n-1].Data) != `"`+payload+`"` {
+		t.Errorf("reloaded %d entries, want %d done with their data", len(entries), n)
+	}
+}
+
+func TestFileClearAndTruncatedRecord(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "frontier.json")
+	f, err := NewFile(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Add(ctx, "gone", 0, "a")
+	f.Clear(ctx, "gone")
+	f.Add(ctx, "cat", 0, "a", "b")
+	f.Done(ctx, "cat", "a", 1)
+	f.Done(ctx, "cat", "b", 2)
+
+	// A crash in the middle of the last append
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewFile(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses(t, reloaded, "cat"); got != "a=done b=queued" {
+		t.Errorf("reloaded = %q, want the cut record dropped", got)
+	}
+	if got := statuses(t, reloaded, "gone"); got != "" {
+		t.Errorf("cleared scope = %q, want nothing", got)
+	}
+	// The log was rewritten without the cut record, so appending works
+	reloaded.Done(ctx, "cat", "b", 2)
+	again, err := NewFile(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statuses(t, again, "cat"); got != "a=done b=done" {
+		t.Errorf("after another run = %q", got)
+	}
+}
@@ -0,0 +1,186 @@
+package frontier
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps the frontier in process. Crawls still retry failed URLs
+// within a run but do not survive a restart; see File for that.
+type Memory struct {
+	maxAttempts int
+	scopes      map[string]*memoryScope
+
+	// changed is called after every update while the lock is held, with
+	// the entries of scope that changed; with none when scope was cleared
+	changed func(scope string, entries []*Entry) error
+	mu      sync.Mutex
+}
+
+type memoryScope struct {
+	Entries []*Entry       `json:"entries"`
+	index   map[string]int // URL to position in Entries
+}
+
+func NewMemory(maxAttempts int) *Memory {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &Memory{maxAttempts: maxAttempts, scopes: map[string]*memoryScope{}}
+}
+
+func (m *Memory) scope(name string) *memoryScope {
+	s, ok := m.scopes[name]
+	if !ok {
+		s = &memoryScope{index: map[string]int{}}
+		m.scopes[name] = s
+	}
+	return s
+}
+
+// entry returns the entry for url, adding it with status if it is new.
+func (m *Memory) entry(scope, url string, status Status) *Entry {
+	s := m.scope(scope)
+	if i, ok := s.index[url]; ok {
+		return s.Entries[i]
+	}
+	e := &Entry{Scope: scope, URL: url, Status: status, UpdatedAt: time.Now().UTC()}
+	s.index[url] = len(s.Entries)
+	s.Entries = append(s.Entries, e)
+	return e
+}
+
+func (m *Memory) save(scope string, entries ...*Entry) error {
+	if m.changed == nil {
+		return nil
+	}
+	return m.changed(scope, entries)
+}
+
+func (m *Memory) Add(ctx context.Context, scope string, depth int, urls ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var added []*Entry
+	for _, url := range urls {
+		if _, ok := m.scope(scope).index[url]; ok {
+			continue
+		}
+		e := m.entry(scope, url, Queued)
+		e.Depth = depth
+		added = append(added, e)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	return m.save(scope, added...)
+}
+
+func (m *Memory) Next(ctx context.Context, scope string, n int) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var claimed []Entry
+	var changed []*Entry
+	for _, e := range m.scope(scope).Entries {
+		if len(claimed) == n {
+			break
+		}
+		if e.Status != Queued {
+			continue
+		}
+		e.Status = InProgress
+		e.UpdatedAt = time.Now().UTC()
+		claimed = append(claimed, *e)
+		changed = append(changed, e)
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+	return claimed, m.save(scope, changed...)
+}
+
+func (m *Memory) Done(ctx context.Context, scope, url string, data any) error {
+	raw, err := encode(data)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entry(scope, url, Done)
+	e.Status = Done
+	e.Attempts++
+	e.LastError = ""
+	e.Data = raw
+	e.UpdatedAt = time.Now().UTC()
+	return m.save(scope, e)
+}
+
+func (m *Memory) Fail(ctx context.Context, scope, url string, cause error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := m.entry(scope, url, Queued)
+	e.Attempts++
+	e.Status = failedStatus(e.Attempts, m.maxAttempts, cause)
+	e.LastError = cause.Error()
+	e.UpdatedAt = time.Now().UTC()
+	return m.save(scope, e)
+}
+
+func (m *Memory) Entries(ctx context.Context, scope string) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.scopes[scope]
+	if !ok {
+		return nil, nil
+	}
+	entries := make([]Entry, len(s.Entries))
+	for i, e := range s.Entries {
+		entries[i] = *e
+	}
+	return entries, nil
+}
+
+func (m *Memory) Requeue(ctx context.Context, scope string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.scopes[scope]
+	if !ok {
+		return 0, nil
+	}
+
+	var requeued []*Entry
+	for _, e := range s.Entries {
+		switch e.Status {
+		case Failed:
+			e.Attempts = 0
+		case InProgress:
+		default:
+			continue
+		}
+		e.Status = Queued
+		e.UpdatedAt = time.Now().UTC()
+		requeued = append(requeued, e)
+	}
+	if len(requeued) == 0 {
+		return 0, nil
+	}
+	return len(requeued), m.save(scope, requeued...)
+}
+
+func (m *Memory) Clear(ctx context.Context, scope string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.scopes[scope]; !ok {
+		return nil
+	}
+	delete(m.scopes, scope)
+	return m.save(scope)
+}
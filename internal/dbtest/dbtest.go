@@ -0,0 +1,126 @@
+// Package dbtest gives tests a Postgres database: a migrated schema of a
+// real server when one is configured, or a dry-run connection that only
+// builds SQL.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"trendyol-scraper/migrations"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// EnvDSN names the variable with the DSN of a Postgres server tests may
+// create schemas in, e.g. "host=localhost user=postgres dbname=scraper_test".
+const EnvDSN = "SCRAPER_TEST_DATABASE_DSN"
+
+var schemaSeq atomic.Uint64
+
+// Open returns a connection to a fresh schema with every migration applied,
+// dropped again when the test ends. Each test gets its own schema, so
+// packages can run in parallel against one server. The test is skipped when
+// EnvDSN is not set.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(EnvDSN)
+	if dsn == "" {
+		t.Skipf("%s is not set", EnvDSN)
+	}
+	config := &gorm.Config{Logger: logger.Discard}
+
+	admin, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d_%d_%d", os.Getpid(), time.Now().UnixNano(), schemaSeq.Add(1))
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("failed to create schema %s: %v", schema, err)
+	}
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema)), config)
+	if err != nil {
+		t.Fatalf("failed to connect to schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// withSearchPath adds search_path to a keyword/value or URL DSN.
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
+
+// DryRun returns a connection that builds SQL without sending it, and a
+// function returning the statements built so far with their arguments
+// inlined. Queries find no rows.
+func DryRun(t testing.TB) (*gorm.DB, func() []string) {
+	t.Helper()
+	rec := &recorder{}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=dry-run"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               rec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, rec.statements
+}
+
+// recorder is a gorm logger that keeps every statement.
+type recorder struct {
+	mu  sync.Mutex
+	sql []string
+}
+
+func (r *recorder) LogMode(logger.LogLevel) logger.Interface      { return r }
+func (r *recorder) Info(context.Context, string, ...interface{})  {}
+func (r *recorder) Warn(context.Context, string, ...interface{})  {}
+func (r *recorder) Error(context.Context, string, ...interface{}) {}
+
+func (r *recorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sql = append(r.sql, sql)
+}
+
+func (r *recorder) statements() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sql...)
+}
@@ -2,104 +2,177 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 	"trendyol-scraper/config"
-	"trendyol-scraper/models"
-	"trendyol-scraper/scraper"
-	"trendyol-scraper/storage"
-
-	"github.com/IBM/sarama"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
 func main() {
-	// Load configuration
-	cfg, err := config.LoadConfig("config.yaml")
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatalf("Error: %v", err)
 	}
+}
 
-	// Initialize database
-	dsn := buildDSN(cfg)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Error initializing database: %v", err)
+func run(args []string) error {
+	global := flag.NewFlagSet("trendyol-scraper", flag.ContinueOnError)
+	configPath := global.String("config", "config.yaml", "path to the YAML config file")
+	autoMigrate := global.Bool("auto-migrate", true, "apply pending schema migrations before running a command")
+	overrides := addConfigFlags(global)
+	global.Usage = func() { printUsage(global) }
+
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	if global.NArg() == 0 {
+		printUsage(global)
+		return fmt.Errorf("no command given")
 	}
 
-	// Drop existing tables
-	if err := db.Migrator().DropTable(
-		&models.Favorite{},
-		&models.Notification{},
-		&models.PriceHistory{},
-		&models.Product{},
-	); err != nil {
-		log.Printf("Warning: Failed to drop tables: %v", err)
+	name := global.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		printUsage(global)
+		return fmt.Errorf("unknown command %q", name)
 	}
 
-	// Auto migrate models
-	if err := db.AutoMigrate(
-		&models.Product{},
-		&models.PriceHistory{},
-		&models.Notification{},
-		&models.Favorite{},
-	); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+	// Load configuration
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
 	}
+	overrides.apply(global, cfg)
+	cfg.ApplyDefaults()
 
-	// Initialize storage handler
-	var storageHandler storage.StorageHandler
-	if cfg.Scraper.OutputFormat == "db" {
-		var err error
-		storageHandler, err = storage.NewDatabaseStorage(db)
-		if err != nil {
-			log.Fatalf("Failed to initialize database storage: %v", err)
+	// `config` reports validation problems itself, and `fixtures` only
+	// needs the selector profiles
+	if name != "config" && name != "fixtures" {
+		if err := cfg.Validate(); err != nil {
+			return err
 		}
-	} else {
-		storageHandler = storage.NewJSONStorage(cfg)
 	}
 
-	// Initialize Kafka producer (for price drop notifications)
-	kafkaConfig := sarama.NewConfig()
-	kafkaConfig.Producer.RequiredAcks = sarama.WaitForAll
-	kafkaConfig.Producer.Return.Successes = true
-	kafkaConfig.Net.MaxOpenRequests = 1
+	a := newApp(cfg, *autoMigrate)
+	finished := true // false while the command may still use a
+	defer func() {
+		if finished {
+			a.close()
+		}
+	}()
 
-	kafkaProducer, err := sarama.NewSyncProducer([]string{"localhost:9092"}, kafkaConfig)
-	if err != nil {
-		log.Fatalf("Failed to create Kafka producer: %v", err)
-	}
-	defer kafkaProducer.Close()
+	// SIGINT/SIGTERM cancel the root context; a second signal kills the
+	// process immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Initialize services
-	productAnalysisSvc := &ProductAnalysisService{
-		db:             db,
-		storageHandler: storageHandler,
-		kafkaProducer:  kafkaProducer,
+	done := make(chan error, 1)
+	go func() { done <- cmd.run(ctx, a, global.Args()[1:]) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
 	}
+	stop()
 
-	// Process mock data
-	mockProcessor := scraper.NewMockProcessor("data.json")
-	products, err := mockProcessor.ProcessMockData()
-	if err != nil {
-		log.Fatalf("Failed to process mock data: %v", err)
+	timeout := time.Duration(cfg.App.ShutdownTimeoutSeconds) * time.Second
+	log.Printf("Shutting down, waiting up to %s for in-flight work", timeout)
+	select {
+	case err := <-done:
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+		log.Printf("Shutdown complete")
+		return nil
+	case <-time.After(timeout):
+		// The command still holds the database and the bus; closing them
+		// under it would only turn its work into errors, and the process
+		// is about to exit anyway
+		finished = false
+		return fmt.Errorf("shutdown deadline of %s exceeded", timeout)
 	}
+}
+
+func printUsage(global *flag.FlagSet) {
+	out := global.Output()
+	fmt.Fprintf(out, "Usage: trendyol-scraper [global flags] <command> [command flags]\n\nCommands:\n")
 
-	// Analyze products
-	if err := productAnalysisSvc.ProcessProducts(context.Background(), products); err != nil {
-		log.Fatalf("Failed to process products: %v", err)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-10s %s\n", name, commands[name].summary)
+		for _, u := range commands[name].usage {
+			fmt.Fprintf(out, "      %s\n", u)
+		}
 	}
 
-	// Start notification service (in a separate goroutine)
-	notificationSvc := &NotificationService{db: db}
-	go notificationSvc.StartConsumer()
+	fmt.Fprintf(out, "\nGlobal flags:\n")
+	global.PrintDefaults()
+}
+
+// configOverrides holds global flags that, when set explicitly, take
+// precedence over the values loaded from the config file.
+type configOverrides struct {
+	dbHost         *string
+	dbPort         *int
+	dbName         *string
+	dbUser         *string
+	kafkaBrokers   *string
+	outputFormat   *string
+	jsonOutputPath *string
+	baseURL        *string
+	delaySeconds   *int
+	maxDepth       *int
+}
 
-	// Keep main running
-	select {}
+func addConfigFlags(fs *flag.FlagSet) *configOverrides {
+	return &configOverrides{
+		dbHost:         fs.String("db-host", "", "override database.host"),
+		dbPort:         fs.Int("db-port", 0, "override database.port"),
+		dbName:         fs.String("db-name", "", "override database.name"),
+		dbUser:         fs.String("db-user", "", "override database.user"),
+		kafkaBrokers:   fs.String("kafka-brokers", "", "override kafka.brokers (comma separated)"),
+		outputFormat:   fs.String("output-format", "", "override scraper.output_format (db or json)"),
+		jsonOutputPath: fs.String("json-output-path", "", "override scraper.json_output_path"),
+		baseURL:        fs.String("base-url", "", "override scraper.base_url"),
+		delaySeconds:   fs.Int("delay", 0, "override scraper.delay_seconds"),
+		maxDepth:       fs.Int("max-depth", 0, "override scraper.max_depth"),
+	}
 }
 
-func buildDSN(cfg *config.Config) string {
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
-		cfg.Database.Host, cfg.Database.User, cfg.Database.Password, cfg.Database.Name, cfg.Database.Port)
+func (o *configOverrides) apply(fs *flag.FlagSet, cfg *config.Config) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "db-host":
+			cfg.Database.Host = *o.dbHost
+		case "db-port":
+			cfg.Database.Port = *o.dbPort
+		case "db-name":
+			cfg.Database.Name = *o.dbName
+		case "db-user":
+			cfg.Database.User = *o.dbUser
+		case "kafka-brokers":
+			cfg.Kafka.Brokers = strings.Split(*o.kafkaBrokers, ",")
+		case "output-format":
+			cfg.Scraper.OutputFormat = *o.outputFormat
+		case "json-output-path":
+			cfg.Scraper.JSONOutputPath = *o.jsonOutputPath
+		case "base-url":
+			cfg.Scraper.BaseURL = *o.baseURL
+		case "delay":
+			cfg.Scraper.DelaySeconds = *o.delaySeconds
+		case "max-depth":
+			cfg.Scraper.MaxDepth = *o.maxDepth
+		}
+	})
 }
@@ -0,0 +1,83 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"trendyol-scraper/config"
+)
+
+// Message is a single event travelling over the bus.
+type Message struct {
+	Topic string
+	Key   []byte
+	Value []byte
+}
+
+// Handler processes one message. Returning an error asks the backend to
+// retry the message; it is not acknowledged until a handler call succeeds.
+type Handler func(ctx context.Context, msg Message) error
+
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+type Subscriber interface {
+	// Subscribe blocks, delivering messages for topic to handler until ctx
+	// is cancelled or the subscriber is closed.
+	Subscribe(ctx context.Context, topic string, handler Handler) error
+	Close() error
+}
+
+// Bus is implemented by every backend so one instance can be shared by the
+// producing and consuming side of the pipeline.
+type Bus interface {
+	Publisher
+	Subscriber
+}
+
+// New returns the backend selected by bus.driver.
+func New(cfg *config.Config) (Bus, error) {
+	switch cfg.Bus.Driver {
+	case "", "kafka":
+		return NewKafkaBus(cfg), nil
+	case "memory":
+		return NewMemoryBus(cfg.Bus.MaxRetries), nil
+	case "file":
+		if cfg.Bus.FilePath == "" {
+			return nil, fmt.Errorf("bus.file_path is required for the file driver")
+		}
+		return NewFileBus(cfg.Bus.FilePath, cfg.Bus.MaxRetries), nil
+	default:
+		return nil, fmt.Errorf("unknown bus driver %q (want kafka, memory or file)", cfg.Bus.Driver)
+	}
+}
+
+// deliver calls handler until it succeeds, doubling the wait between
+// attempts. The last error is returned once all attempts are used up.
+func deliver(ctx context.Context, handler Handler, msg Message, attempts int) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	backoff := 500 * time.Millisecond
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = handler(ctx, msg); err == nil {
+			return nil
+		}
+		log.Printf("Handler failed for %s message (attempt %d/%d): %v", msg.Topic, attempt, attempts, err)
+
+		if attempt < attempts {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+package messaging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileRecord is one line of the NDJSON event log.
+type fileRecord struct {
+	Topic string          `json:"topic"`
+	Key   string          `json:"key,omitempty"`
+	Value json.RawMessage `json:"value"`
+	At    time.Time       `json:"at"`
+}
+
+// FileBus appends events to an NDJSON file and delivers them to subscribers,
+// which makes it possible to record a run and feed it to the consumer later
+// without a broker. Subscribers remember how far they read, see Subscribe.
+type FileBus struct {
+	path       string
+	maxRetries int
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileBus(path string, maxRetries int) *FileBus {
+	return &FileBus{path: path, maxRetries: maxRetries}
+}
+
+func (b *FileBus) Publish(ctx context.Context, msg Message) error {
+	if !json.Valid(msg.Value) {
+		return fmt.Errorf("file bus only accepts JSON message values")
+	}
+
+	line, err := json.Marshal(fileRecord{
+		Topic: msg.Topic,
+		Key:   string(msg.Key),
+		Value: msg.Value,
+		At:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.file == nil {
+		if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+			return fmt.Errorf("failed to create event log directory: %w", err)
+		}
+		f, err := os.OpenFile(b.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open event log: %w", err)
+		}
+		b.file = f
+	}
+
+	if _, err := b.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to event log: %w", err)
+	}
+	return nil
+}
+
+// Subscribe delivers the events of topic from where the last subscriber
+// stopped and then follows the log for new ones until ctx is cancelled.
+// The read position is kept per topic in <file_path>.<topic>.offset; delete
+// it to replay the log from the beginning. The log is created if nothing
+// was published yet.
+func (b *FileBus) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return fmt.Errorf("failed to create event log directory: %w", err)
+	}
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer f.Close()
+
+	offsetPath := b.path + "." + topic + ".offset"
+	offset, err := readOffset(offsetPath)
+	if err != nil {
+		return err
+	}
+	if info, err := f.Stat(); err == nil && offset > info.Size() {
+		log.Printf("Event log %s is shorter than the saved offset, reading it from the start", b.path)
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek event log: %w", err)
+	}
+	saved := offset
+
+	reader := bufio.NewReader(f)
+	var partial []byte
+	for {
+		chunk, err := reader.ReadBytes('\n')
+		partial = append(partial, chunk...)
+
+		if err == io.EOF {
+			// Lines of other topics move the offset too
+			if offset != saved {
+				if err := writeOffset(offsetPath, offset); err != nil {
+					return err
+				}
+				saved = offset
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(500 * time.Millisecond):
+				continue
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to read event log: %w", err)
+		}
+
+		line := partial
+		partial = nil
+		offset += int64(len(line))
+
+		var rec fileRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("Skipping malformed event log line: %v", err)
+			continue
+		}
+		if rec.Topic != topic {
+			continue
+		}
+
+		msg := Message{Topic: rec.Topic, Key: []byte(rec.Key), Value: rec.Value}
+		if err := deliver(ctx, handler, msg, b.maxRetries); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("Skipping %s event after failed delivery: %v", topic, err)
+		}
+		if err := writeOffset(offsetPath, offset); err != nil {
+			return err
+		}
+		saved = offset
+	}
+}
+
+func readOffset(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read event log offset: %w", err)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid event log offset in %s", path)
+	}
+	return offset, nil
+}
+
+// writeOffset replaces the offset file in one rename, so a crash leaves
+// either the old or the new offset.
+func writeOffset(path string, offset int64) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)), 0644); err != nil {
+		return fmt.Errorf("failed to save event log offset: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save event log offset: %w", err)
+	}
+	return nil
+}
+
+func (b *FileBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	b.file = nil
+	return err
+}
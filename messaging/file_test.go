@@ -0,0 +1,145 @@
+package messaging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// subscribe runs Subscribe until want messages arrived and returns them.
+func subscribe(t *testing.T, bus Subscriber, topic string, want int) []Message {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan Message, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- bus.Subscribe(ctx, topic, func(ctx context.Context, msg Message) error {
+			received <- msg
+			return nil
+		})
+	}()
+
+	var msgs []Message
+	timeout := time.After(5 * time.Second)
+	for len(msgs) < want {
+		select {
+		case msg := <-received:
+			msgs = append(msgs, msg)
+		case err := <-done:
+			t.Fatalf("Subscribe returned %v after %d of %d messages", err, len(msgs), want)
+		case <-timeout:
+			t.Fatalf("received %d of %d messages", len(msgs), want)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	return msgs
+}
+
+func publish(t *testing.T, bus Publisher, topic string, values ...string) {
+	t.Helper()
+	for _, v := range values {
+		if err := bus.Publish(context.Background(), Message{Topic: topic, Key: []byte("k"), Value: []byte(v)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func values(msgs []Message) []string {
+	var vs []string
+	for _, m := range msgs {
+		vs = append(vs, string(m.Value))
+	}
+	return vs
+}
+
+func TestFileBusResumesFromOffset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "bus.ndjson")
+	bus := NewFileBus(path, 1)
+	defer bus.Close()
+
+	publish(t, bus, "price-drops", `1`, `2`)
+	publish(t, bus, "other", `"x"`)
+	publish(t, bus, "price-drops", `3`)
+	if got := values(subscribe(t, bus, "price-drops", 3)); len(got) != 3 || got[0] != "1" || got[2] != "3" {
+		t.Fatalf("first subscriber got %v, want 1 2 3", got)
+	}
+
+	// A later subscriber starts after what was delivered
+	publish(t, bus, "price-drops", `4`)
+	if got := values(subscribe(t, bus, "price-drops", 1)); got[0] != "4" {
+		t.Errorf("second subscriber got %v, want 4", got)
+	}
+
+	// The offset is replaced through a rename, leaving no temporary file
+	for _, name := range []string{path + ".price-drops.offset.tmp", path + ".other.offset"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s exists: %v", name, err)
+		}
+	}
+	offset, err := readOffset(path + ".price-drops.offset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || offset != info.Size() {
+		t.Errorf("offset = %d, want the end of the log (%v)", offset, info.Size())
+	}
+}
+
+func TestFileBusLogReplaced(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bus.ndjson")
+	bus := NewFileBus(path, 1)
+	publish(t, bus, "price-drops", `1`, `2`, `3`)
+	subscribe(t, bus, "price-drops", 3)
+	bus.Close()
+
+	// The log was rotated away and a new one started, shorter than the
+	// saved offset, so it is read from the start
+	if err := os.Rename(path, path+".old"); err != nil {
+		t.Fatal(err)
+	}
+	bus = NewFileBus(path, 1)
+	defer bus.Close()
+	publish(t, bus, "price-drops", `9`)
+	if got := values(subscribe(t, bus, "price-drops", 1)); got[0] != "9" {
+		t.Errorf("got %v, want 9", got)
+	}
+}
+
+func TestFileBusPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bus.ndjson")
+	bus := NewFileBus(path, 1)
+	defer bus.Close()
+
+	line := `{"topic":"price-drops","value":{"productId":7},"at":"2025-05-01T12:00:00Z"}` + "\n"
+	if err := os.WriteFile(path, []byte(line[:20]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		// The rest of the line is written while the subscriber waits
+		time.Sleep(100 * time.Millisecond)
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+		f.WriteString(line[20:])
+		f.Close()
+	}()
+	if got := values(subscribe(t, bus, "price-drops", 1)); got[0] != `{"productId":7}` {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestFileBusRejectsNonJSON(t *testing.T) {
+	bus := NewFileBus(filepath.Join(t.TempDir(), "bus.ndjson"), 1)
+	defer bus.Close()
+	if err := bus.Publish(context.Background(), Message{Topic: "t", Value: []byte("not json")}); err == nil {
+		t.Error("Publish accepted a value that is not JSON")
+	}
+}
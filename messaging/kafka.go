@@ -0,0 +1,200 @@
+package messaging
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"trendyol-scraper/config"
+
+	"github.com/IBM/sarama"
+)
+
+// NewSaramaConfig builds a sarama configuration from the kafka section of
+// the application config. Producer and consumer settings are both filled in
+// so the same config can be used for either side.
+func NewSaramaConfig(cfg *config.Config) (*sarama.Config, error) {
+	kc := cfg.Kafka
+	sc := sarama.NewConfig()
+
+	if kc.ClientID != "" {
+		sc.ClientID = kc.ClientID
+	}
+
+	if kc.Version != "" {
+		version, err := sarama.ParseKafkaVersion(kc.Version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kafka version %q: %w", kc.Version, err)
+		}
+		sc.Version = version
+	}
+
+	sc.Producer.RequiredAcks = sarama.WaitForAll
+	sc.Producer.Return.Successes = true
+	sc.Net.MaxOpenRequests = 1
+
+	switch strings.ToLower(kc.Compression) {
+	case "", "none":
+		sc.Producer.Compression = sarama.CompressionNone
+	case "gzip":
+		sc.Producer.Compression = sarama.CompressionGZIP
+	case "snappy":
+		sc.Producer.Compression = sarama.CompressionSnappy
+	case "lz4":
+		sc.Producer.Compression = sarama.CompressionLZ4
+	case "zstd":
+		sc.Producer.Compression = sarama.CompressionZSTD
+	default:
+		return nil, fmt.Errorf("unknown kafka compression %q", kc.Compression)
+	}
+
+	switch strings.ToLower(kc.Partitioner) {
+	case "", "hash":
+		sc.Producer.Partitioner = sarama.NewHashPartitioner
+	case "random":
+		sc.Producer.Partitioner = sarama.NewRandomPartitioner
+	case "roundrobin":
+		sc.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	default:
+		return nil, fmt.Errorf("unknown kafka partitioner %q", kc.Partitioner)
+	}
+
+	switch strings.ToLower(kc.RebalanceStrategy) {
+	case "", "range":
+		sc.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRange()}
+	case "roundrobin":
+		sc.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	case "sticky":
+		sc.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	default:
+		return nil, fmt.Errorf("unknown kafka rebalance strategy %q", kc.RebalanceStrategy)
+	}
+
+	switch strings.ToLower(kc.InitialOffset) {
+	case "", "oldest":
+		sc.Consumer.Offsets.Initial = sarama.OffsetOldest
+	case "newest":
+		sc.Consumer.Offsets.Initial = sarama.OffsetNewest
+	default:
+		return nil, fmt.Errorf("unknown kafka initial offset %q", kc.InitialOffset)
+	}
+
+	// Offsets are only marked after a message was handled successfully, and
+	// the auto-committer flushes marked offsets in the background.
+	sc.Consumer.Offsets.AutoCommit.Enable = true
+	sc.Consumer.Return.Errors = true
+
+	if kc.SASL.Enabled {
+		sc.Net.SASL.Enable = true
+		sc.Net.SASL.User = kc.SASL.Username
+		sc.Net.SASL.Password = kc.SASL.Password
+
+		switch strings.ToUpper(kc.SASL.Mechanism) {
+		case "", sarama.SASLTypePlaintext:
+			sc.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		case sarama.SASLTypeSCRAMSHA256:
+			sc.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+			sc.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{HashGeneratorFcn: sha256Generator}
+			}
+		case sarama.SASLTypeSCRAMSHA512:
+			sc.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+			sc.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+				return &scramClient{HashGeneratorFcn: sha512Generator}
+			}
+		default:
+			return nil, fmt.Errorf("unsupported kafka SASL mechanism %q", kc.SASL.Mechanism)
+		}
+	}
+
+	if kc.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sc.Net.TLS.Enable = true
+		sc.Net.TLS.Config = tlsConfig
+	}
+
+	if err := sc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka config: %w", err)
+	}
+	return sc, nil
+}
+
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	kc := cfg.Kafka
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: kc.TLS.InsecureSkipVerify,
+	}
+
+	if kc.TLS.CAFile != "" {
+		caCert, err := os.ReadFile(kc.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kafka CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in kafka CA file %s", kc.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if kc.TLS.CertFile != "" || kc.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(kc.TLS.CertFile, kc.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kafka client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func brokers(cfg *config.Config) ([]string, error) {
+	if len(cfg.Kafka.Brokers) == 0 {
+		return nil, fmt.Errorf("no kafka brokers configured")
+	}
+	return cfg.Kafka.Brokers, nil
+}
+
+// NewSyncProducer creates a producer connected to the configured brokers.
+func NewSyncProducer(cfg *config.Config) (sarama.SyncProducer, error) {
+	addrs, err := brokers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := NewSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducer(addrs, sc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
+	}
+	return producer, nil
+}
+
+// NewConsumerGroup joins the configured consumer group.
+func NewConsumerGroup(cfg *config.Config) (sarama.ConsumerGroup, error) {
+	addrs, err := brokers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Kafka.GroupID == "" {
+		return nil, fmt.Errorf("kafka group_id is required for consumers")
+	}
+	sc, err := NewSaramaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := sarama.NewConsumerGroup(addrs, cfg.Kafka.GroupID, sc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka consumer group: %w", err)
+	}
+	return group, nil
+}
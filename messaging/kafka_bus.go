@@ -0,0 +1,175 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"trendyol-scraper/config"
+
+	"github.com/IBM/sarama"
+)
+
+// KafkaBus publishes with a sync producer and subscribes through the
+// configured consumer group. Clients are created on first use so a process
+// that only consumes never opens a producer.
+type KafkaBus struct {
+	cfg *config.Config
+
+	mu       sync.Mutex
+	producer sarama.SyncProducer
+	groups   []sarama.ConsumerGroup
+}
+
+func NewKafkaBus(cfg *config.Config) *KafkaBus {
+	return &KafkaBus{cfg: cfg}
+}
+
+func (b *KafkaBus) syncProducer() (sarama.SyncProducer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.producer == nil {
+		producer, err := NewSyncProducer(b.cfg)
+		if err != nil {
+			return nil, err
+		}
+		b.producer = producer
+	}
+	return b.producer, nil
+}
+
+func (b *KafkaBus) Publish(ctx context.Context, msg Message) error {
+	producer, err := b.syncProducer()
+	if err != nil {
+		return err
+	}
+
+	pm := &sarama.ProducerMessage{
+		Topic: msg.Topic,
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	if len(msg.Key) > 0 {
+		pm.Key = sarama.ByteEncoder(msg.Key)
+	}
+
+	if _, _, err := producer.SendMessage(pm); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", msg.Topic, err)
+	}
+	return nil
+}
+
+// Subscribe consumes topic as part of the configured group. Sessions are
+// re-joined after every rebalance until ctx is cancelled.
+func (b *KafkaBus) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	group, err := NewConsumerGroup(b.cfg)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.groups = append(b.groups, group)
+	b.mu.Unlock()
+
+	go func() {
+		for err := range group.Errors() {
+			log.Printf("Consumer error: %v", err)
+		}
+	}()
+
+	gh := &groupHandler{handler: handler, maxRetries: b.cfg.Bus.MaxRetries}
+	if dlt := b.cfg.Kafka.DeadLetterTopic; dlt != "" {
+		gh.deadLetter = func(ctx context.Context, msg Message) error {
+			msg.Topic = dlt
+			return b.Publish(ctx, msg)
+		}
+	}
+	for {
+		if err := group.Consume(ctx, []string{topic}, gh); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return nil
+			}
+			log.Printf("Consumer group session ended: %v", err)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (b *KafkaBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var errs []error
+	for _, group := range b.groups {
+		errs = append(errs, group.Close())
+	}
+	b.groups = nil
+	if b.producer != nil {
+		errs = append(errs, b.producer.Close())
+		b.producer = nil
+	}
+	return errors.Join(errs...)
+}
+
+// groupHandler adapts a Handler to sarama.ConsumerGroupHandler.
+type groupHandler struct {
+	handler    Handler
+	maxRetries int
+	// deadLetter receives messages that failed every attempt; nil skips them
+	deadLetter func(ctx context.Context, msg Message) error
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim.
+func (h *groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	log.Printf("Consumer group session started (member %s, claims %v)", session.MemberID(), session.Claims())
+	return nil
+}
+
+// Cleanup is run at the end of a session, once all ConsumeClaim goroutines
+// have exited. Marked offsets are committed before partitions are handed to
+// another member.
+func (h *groupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+	return nil
+}
+
+// ConsumeClaim marks a message once the handler succeeded. A message that
+// still fails after all attempts is sent to the dead-letter topic, or logged
+// and skipped without one, and then marked too, so one bad message cannot
+// hold up its partition.
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx := session.Context()
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			m := Message{Topic: msg.Topic, Key: msg.Key, Value: msg.Value}
+			if err := deliver(ctx, h.handler, m, h.maxRetries); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				if h.deadLetter == nil {
+					log.Printf("Skipping %s/%d@%d after failed delivery: %v", msg.Topic, msg.Partition, msg.Offset, err)
+				} else if dlErr := h.deadLetter(ctx, m); dlErr != nil {
+					// Not marked, so the message is redelivered after the session ends
+					return fmt.Errorf("failed to dead-letter %s/%d@%d: %w", msg.Topic, msg.Partition, msg.Offset, dlErr)
+				} else {
+					log.Printf("Moved %s/%d@%d to the dead-letter topic after failed delivery: %v", msg.Topic, msg.Partition, msg.Offset, err)
+				}
+			}
+			session.MarkMessage(msg, "")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
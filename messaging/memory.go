@@ -0,0 +1,92 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+const memoryQueueSize = 1024
+
+var ErrBusClosed = errors.New("message bus is closed")
+
+// MemoryBus is an in-process bus backed by one buffered channel per topic.
+// Subscribers of the same topic compete for messages like members of a
+// consumer group, and messages published before anyone subscribes stay
+// queued until they are picked up.
+type MemoryBus struct {
+	mu         sync.Mutex
+	topics     map[string]chan Message
+	closed     chan struct{}
+	closeOnce  sync.Once
+	maxRetries int
+}
+
+func NewMemoryBus(maxRetries int) *MemoryBus {
+	return &MemoryBus{
+		topics:     make(map[string]chan Message),
+		closed:     make(chan struct{}),
+		maxRetries: maxRetries,
+	}
+}
+
+func (b *MemoryBus) queue(topic string) chan Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.topics[topic]
+	if !ok {
+		q = make(chan Message, memoryQueueSize)
+		b.topics[topic] = q
+	}
+	return q
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, msg Message) error {
+	select {
+	case <-b.closed:
+		return ErrBusClosed
+	default:
+	}
+
+	select {
+	case b.queue(msg.Topic) <- msg:
+		return nil
+	case <-b.closed:
+		return ErrBusClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe drops a message after its retries are used up, since there is
+// no durable log to redeliver it from.
+func (b *MemoryBus) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	q := b.queue(topic)
+	for {
+		select {
+		case msg := <-q:
+			if err := deliver(ctx, handler, msg, b.maxRetries); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				log.Printf("Dropping %s message after failed delivery: %v", topic, err)
+			}
+		case <-b.closed:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Pending reports how many messages are queued for topic.
+func (b *MemoryBus) Pending(topic string) int {
+	return len(b.queue(topic))
+}
+
+func (b *MemoryBus) Close() error {
+	b.closeOnce.Do(func() { close(b.closed) })
+	return nil
+}
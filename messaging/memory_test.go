@@ -0,0 +1,74 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryBusQueuesUntilSubscribed(t *testing.T) {
+	bus := NewMemoryBus(1)
+	defer bus.Close()
+
+	publish(t, bus, "price-drops", "1", "2")
+	publish(t, bus, "other", "x")
+	if n := bus.Pending("price-drops"); n != 2 {
+		t.Errorf("Pending() = %d, want 2", n)
+	}
+	if got := values(subscribe(t, bus, "price-drops", 2)); got[0] != "1" || got[1] != "2" {
+		t.Errorf("got %v, want 1 2 in order", got)
+	}
+	if n := bus.Pending("other"); n != 1 {
+		t.Errorf("other topic has %d pending, want 1", n)
+	}
+}
+
+func TestMemoryBusDropsAfterRetries(t *testing.T) {
+	bus := NewMemoryBus(1)
+	defer bus.Close()
+	publish(t, bus, "price-drops", "bad", "good")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := make(chan string, 4)
+	go bus.Subscribe(ctx, "price-drops", func(ctx context.Context, msg Message) error {
+		calls <- string(msg.Value)
+		if string(msg.Value) == "bad" {
+			return errors.New("handler failed")
+		}
+		return nil
+	})
+
+	for _, want := range []string{"bad", "good"} {
+		select {
+		case got := <-calls:
+			if got != want {
+				t.Errorf("handled %s, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was not handled", want)
+		}
+	}
+}
+
+func TestMemoryBusClose(t *testing.T) {
+	bus := NewMemoryBus(1)
+	done := make(chan error, 1)
+	go func() {
+		done <- bus.Subscribe(context.Background(), "price-drops", func(context.Context, Message) error { return nil })
+	}()
+
+	bus.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Subscribe() = %v after Close", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribe did not return after Close")
+	}
+	if err := bus.Publish(context.Background(), Message{Topic: "price-drops"}); !errors.Is(err, ErrBusClosed) {
+		t.Errorf("Publish() after Close = %v, want ErrBusClosed", err)
+	}
+}
@@ -0,0 +1,97 @@
+package migrations
+
+// The initial schema is the one the later migrations build on. It follows
+// the tables AutoMigrate used to create, except that ids the site assigns
+// are plain BIGINT where AutoMigrate made them BIGSERIAL. CREATE TABLE IF
+// NOT EXISTS leaves tables that already exist as they are, so a database
+// set up before migrations existed keeps its own column types and defaults
+// and is only changed by the later migrations.
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: `
+CREATE TABLE IF NOT EXISTS products (
+	id               BIGINT PRIMARY KEY,
+	name             TEXT,
+	url              TEXT,
+	brand            TEXT,
+	brand_id         BIGINT,
+	merchant_id      BIGINT,
+	category_id      BIGINT,
+	image_url        TEXT,
+	average_rating   DOUBLE PRECISION,
+	total_count      BIGINT,
+	selling_price    DOUBLE PRECISION,
+	discounted_price DOUBLE PRECISION,
+	original_price   DOUBLE PRECISION,
+	currency         TEXT,
+	promotions       JSONB,
+	social_proof     JSONB,
+	is_active        BOOLEAN DEFAULT TRUE,
+	created_at       TIMESTAMPTZ,
+	updated_at       TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS price_histories (
+	id          BIGSERIAL PRIMARY KEY,
+	product_id  TEXT,
+	price       DOUBLE PRECISION,
+	recorded_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+	id         BIGSERIAL PRIMARY KEY,
+	product_id BIGINT REFERENCES products(id),
+	message    TEXT,
+	type       TEXT,
+	sent       BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS favorites (
+	id         BIGSERIAL PRIMARY KEY,
+	user_id    TEXT,
+	product_id BIGINT REFERENCES products(id),
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+	id            TEXT PRIMARY KEY,
+	name          TEXT,
+	url           TEXT,
+	parent_id     TEXT REFERENCES categories(id),
+	is_leaf       BOOLEAN,
+	product_count BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS variants (
+	id         BIGSERIAL PRIMARY KEY,
+	product_id BIGINT,
+	sku        TEXT,
+	name       TEXT,
+	price      DOUBLE PRECISION,
+	stock      BIGINT,
+	available  BOOLEAN
+);
+CREATE INDEX IF NOT EXISTS idx_variants_product_id ON variants (product_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_variants_sku ON variants (sku);
+
+CREATE TABLE IF NOT EXISTS images (
+	id  BIGSERIAL PRIMARY KEY,
+	url TEXT NOT NULL
+);
+`,
+		Down: `
+DROP TABLE IF EXISTS images;
+DROP TABLE IF EXISTS variants;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS favorites;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS price_histories;
+DROP TABLE IF EXISTS products;
+`,
+	})
+}
@@ -0,0 +1,24 @@
+package migrations
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "outbox_events",
+		Up: `
+CREATE TABLE outbox_events (
+	id           BIGSERIAL PRIMARY KEY,
+	topic        TEXT NOT NULL,
+	key          TEXT,
+	payload      JSONB NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	last_error   TEXT,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	delivered_at TIMESTAMPTZ
+);
+CREATE INDEX idx_outbox_events_pending ON outbox_events (id) WHERE delivered_at IS NULL;
+`,
+		Down: `
+DROP TABLE IF EXISTS outbox_events;
+`,
+	})
+}
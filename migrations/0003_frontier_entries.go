@@ -0,0 +1,27 @@
+package migrations
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "frontier_entries",
+		Up: `
+CREATE TABLE frontier_entries (
+	id         BIGSERIAL PRIMARY KEY,
+	scope      TEXT NOT NULL,
+	url        TEXT NOT NULL,
+	depth      INTEGER NOT NULL DEFAULT 0,
+	status     TEXT NOT NULL,
+	attempts   INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	data       JSONB,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_frontier_entries_scope_url ON frontier_entries (scope, url);
+CREATE INDEX idx_frontier_entries_queued ON frontier_entries (scope, id) WHERE status = 'queued';
+`,
+		Down: `
+DROP TABLE IF EXISTS frontier_entries;
+`,
+	})
+}
@@ -0,0 +1,28 @@
+package migrations
+
+// Variants scraped before this migration never had a product, so they are
+// dropped before the foreign key is added.
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "variant_details",
+		Up: `
+ALTER TABLE variants
+	ADD COLUMN attribute TEXT,
+	ADD COLUMN value     TEXT,
+	ADD COLUMN currency  TEXT;
+DELETE FROM variants WHERE product_id IS NULL OR product_id NOT IN (SELECT id FROM products);
+ALTER TABLE variants
+	ALTER COLUMN product_id SET NOT NULL,
+	ADD CONSTRAINT fk_variants_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
+`,
+		Down: `
+ALTER TABLE variants
+	DROP CONSTRAINT IF EXISTS fk_variants_product,
+	ALTER COLUMN product_id DROP NOT NULL,
+	DROP COLUMN IF EXISTS currency,
+	DROP COLUMN IF EXISTS value,
+	DROP COLUMN IF EXISTS attribute;
+`,
+	})
+}
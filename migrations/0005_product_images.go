@@ -0,0 +1,34 @@
+package migrations
+
+// Images saved before this migration have no product, so they are dropped.
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "product_images",
+		Up: `
+DELETE FROM images;
+ALTER TABLE images
+	ADD COLUMN product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	ADD COLUMN position   INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN width      INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN height     INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN path       TEXT,
+	ADD COLUMN hash       TEXT;
+CREATE UNIQUE INDEX idx_images_url ON images (url);
+CREATE INDEX idx_images_product_id ON images (product_id, position);
+CREATE INDEX idx_images_hash ON images (hash) WHERE hash IS NOT NULL;
+`,
+		Down: `
+DROP INDEX IF EXISTS idx_images_hash;
+DROP INDEX IF EXISTS idx_images_product_id;
+DROP INDEX IF EXISTS idx_images_url;
+ALTER TABLE images
+	DROP COLUMN IF EXISTS hash,
+	DROP COLUMN IF EXISTS path,
+	DROP COLUMN IF EXISTS height,
+	DROP COLUMN IF EXISTS width,
+	DROP COLUMN IF EXISTS position,
+	DROP COLUMN IF EXISTS product_id;
+`,
+	})
+}
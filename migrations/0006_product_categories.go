@@ -0,0 +1,21 @@
+package migrations
+
+// Category IDs are text (see scraper.CategoryID), so products refer to them
+// as text too. There is no foreign key: products are often scraped before
+// the category tree, or from categories deeper than it goes.
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "product_categories",
+		Up: `
+ALTER TABLE products ALTER COLUMN category_id TYPE TEXT USING NULLIF(category_id, 0)::TEXT;
+CREATE INDEX idx_products_category_id ON products (category_id);
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+`,
+		Down: `
+DROP INDEX IF EXISTS idx_categories_parent_id;
+DROP INDEX IF EXISTS idx_products_category_id;
+ALTER TABLE products ALTER COLUMN category_id TYPE BIGINT USING CASE WHEN category_id ~ '^[0-9]+$' THEN category_id::BIGINT END;
+`,
+	})
+}
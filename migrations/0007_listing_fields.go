@@ -0,0 +1,56 @@
+package migrations
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "listing_fields",
+		Up: `
+ALTER TABLE products
+	ADD COLUMN web_brand              TEXT,
+	ADD COLUMN boutique_id            BIGINT,
+	ADD COLUMN item_number            BIGINT,
+	ADD COLUMN listing_id             TEXT,
+	ADD COLUMN discount_percentage    DOUBLE PRECISION,
+	ADD COLUMN rrp_suggested_price    DOUBLE PRECISION,
+	ADD COLUMN rrp_price              DOUBLE PRECISION,
+	ADD COLUMN rrp_selling_price      DOUBLE PRECISION,
+	ADD COLUMN rrp_promotion_price    DOUBLE PRECISION,
+	ADD COLUMN rrp_lowest_recent_price DOUBLE PRECISION,
+	ADD COLUMN rrp_is_price_discounted BOOLEAN,
+	ADD COLUMN rrp_currency           TEXT,
+	ADD COLUMN binary_price           JSONB,
+	ADD COLUMN stamps                 JSONB,
+	ADD COLUMN flash_sale             JSONB,
+	ADD COLUMN on_flash_sale          BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN has_collectable_coupon BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN advert                 JSONB,
+	ADD COLUMN raw                    JSONB;
+CREATE INDEX idx_products_on_flash_sale ON products (id) WHERE on_flash_sale;
+CREATE INDEX idx_products_has_collectable_coupon ON products (id) WHERE has_collectable_coupon;
+`,
+		Down: `
+DROP INDEX IF EXISTS idx_products_has_collectable_coupon;
+DROP INDEX IF EXISTS idx_products_on_flash_sale;
+ALTER TABLE products
+	DROP COLUMN IF EXISTS raw,
+	DROP COLUMN IF EXISTS advert,
+	DROP COLUMN IF EXISTS has_collectable_coupon,
+	DROP COLUMN IF EXISTS on_flash_sale,
+	DROP COLUMN IF EXISTS flash_sale,
+	DROP COLUMN IF EXISTS stamps,
+	DROP COLUMN IF EXISTS binary_price,
+	DROP COLUMN IF EXISTS rrp_currency,
+	DROP COLUMN IF EXISTS rrp_is_price_discounted,
+	DROP COLUMN IF EXISTS rrp_lowest_recent_price,
+	DROP COLUMN IF EXISTS rrp_promotion_price,
+	DROP COLUMN IF EXISTS rrp_selling_price,
+	DROP COLUMN IF EXISTS rrp_price,
+	DROP COLUMN IF EXISTS rrp_suggested_price,
+	DROP COLUMN IF EXISTS discount_percentage,
+	DROP COLUMN IF EXISTS listing_id,
+	DROP COLUMN IF EXISTS item_number,
+	DROP COLUMN IF EXISTS boutique_id,
+	DROP COLUMN IF EXISTS web_brand;
+`,
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "money_columns",
+		Up: `
+ALTER TABLE products
+	ALTER COLUMN selling_price           TYPE NUMERIC(14,2) USING ROUND(selling_price::NUMERIC, 2),
+	ALTER COLUMN discounted_price        TYPE NUMERIC(14,2) USING ROUND(discounted_price::NUMERIC, 2),
+	ALTER COLUMN original_price          TYPE NUMERIC(14,2) USING ROUND(original_price::NUMERIC, 2),
+	ALTER COLUMN rrp_suggested_price     TYPE NUMERIC(14,2) USING ROUND(rrp_suggested_price::NUMERIC, 2),
+	ALTER COLUMN rrp_price               TYPE NUMERIC(14,2) USING ROUND(rrp_price::NUMERIC, 2),
+	ALTER COLUMN rrp_selling_price       TYPE NUMERIC(14,2) USING ROUND(rrp_selling_price::NUMERIC, 2),
+	ALTER COLUMN rrp_promotion_price     TYPE NUMERIC(14,2) USING ROUND(rrp_promotion_price::NUMERIC, 2),
+	ALTER COLUMN rrp_lowest_recent_price TYPE NUMERIC(14,2) USING ROUND(rrp_lowest_recent_price::NUMERIC, 2);
+ALTER TABLE variants
+	ALTER COLUMN price TYPE NUMERIC(14,2) USING ROUND(price::NUMERIC, 2);
+ALTER TABLE price_histories
+	ALTER COLUMN price TYPE NUMERIC(14,2) USING ROUND(price::NUMERIC, 2);
+`,
+		Down: `
+ALTER TABLE price_histories
+	ALTER COLUMN price TYPE DOUBLE PRECISION;
+ALTER TABLE variants
+	ALTER COLUMN price TYPE DOUBLE PRECISION;
+ALTER TABLE products
+	ALTER COLUMN rrp_lowest_recent_price TYPE DOUBLE PRECISION,
+	ALTER COLUMN rrp_promotion_price     TYPE DOUBLE PRECISION,
+	ALTER COLUMN rrp_selling_price       TYPE DOUBLE PRECISION,
+	ALTER COLUMN rrp_price               TYPE DOUBLE PRECISION,
+	ALTER COLUMN rrp_suggested_price     TYPE DOUBLE PRECISION,
+	ALTER COLUMN original_price          TYPE DOUBLE PRECISION,
+	ALTER COLUMN discounted_price        TYPE DOUBLE PRECISION,
+	ALTER COLUMN selling_price           TYPE DOUBLE PRECISION;
+`,
+	})
+}
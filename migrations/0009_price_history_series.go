@@ -0,0 +1,51 @@
+package migrations
+
+// Price history rows so far held only the discounted price, recorded on a
+// change, against a text product ID. Rows for products that are gone or
+// whose ID is not a number are dropped; the rest are kept as changes.
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "price_history_series",
+		Up: `
+DELETE FROM price_histories WHERE product_id IS NULL OR product_id !~ '^[0-9]+$';
+DELETE FROM price_histories WHERE product_id::BIGINT NOT IN (SELECT id FROM products);
+UPDATE price_histories SET recorded_at = NOW() WHERE recorded_at IS NULL;
+ALTER TABLE price_histories RENAME COLUMN price TO discounted_price;
+ALTER TABLE price_histories
+	ALTER COLUMN product_id TYPE BIGINT USING product_id::BIGINT,
+	ALTER COLUMN product_id SET NOT NULL,
+	ALTER COLUMN recorded_at SET NOT NULL,
+	ALTER COLUMN recorded_at SET DEFAULT NOW(),
+	ADD COLUMN selling_price  NUMERIC(14,2),
+	ADD COLUMN original_price NUMERIC(14,2),
+	ADD COLUMN currency       TEXT,
+	ADD COLUMN promotion_id   BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN promotion_name TEXT,
+	ADD COLUMN on_flash_sale  BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN changed        BOOLEAN NOT NULL DEFAULT TRUE,
+	ADD CONSTRAINT fk_price_histories_product
+		FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
+UPDATE price_histories h SET currency = p.currency FROM products p WHERE p.id = h.product_id;
+CREATE INDEX idx_price_histories_product_recorded ON price_histories (product_id, recorded_at);
+`,
+		Down: `
+DROP INDEX IF EXISTS idx_price_histories_product_recorded;
+DELETE FROM price_histories WHERE NOT changed;
+ALTER TABLE price_histories
+	DROP CONSTRAINT IF EXISTS fk_price_histories_product,
+	DROP COLUMN IF EXISTS changed,
+	DROP COLUMN IF EXISTS on_flash_sale,
+	DROP COLUMN IF EXISTS promotion_name,
+	DROP COLUMN IF EXISTS promotion_id,
+	DROP COLUMN IF EXISTS currency,
+	DROP COLUMN IF EXISTS original_price,
+	DROP COLUMN IF EXISTS selling_price,
+	ALTER COLUMN recorded_at DROP DEFAULT,
+	ALTER COLUMN recorded_at DROP NOT NULL,
+	ALTER COLUMN product_id DROP NOT NULL,
+	ALTER COLUMN product_id TYPE TEXT USING product_id::TEXT;
+ALTER TABLE price_histories RENAME COLUMN discounted_price TO price;
+`,
+	})
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAllOrdered(t *testing.T) {
+	all := All()
+	if len(all) == 0 {
+		t.Fatal("no migrations registered")
+	}
+	for i, m := range all {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d, want versions numbered from 1 without gaps", i, m.Version)
+		}
+		if m.Name == "" || strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d lacks a name, Up or Down", m.Version)
+		}
+	}
+}
+
+func TestAllSortsRegistry(t *testing.T) {
+	saved := registry
+	t.Cleanup(func() { registry = saved })
+
+	registry = []Migration{{Version: 3, Name: "c"}, {Version: 1, Name: "a"}, {Version: 2, Name: "b"}}
+	var names []string
+	for _, m := range All() {
+		names = append(names, m.Name)
+	}
+	if got := strings.Join(names, ""); got != "abc" {
+		t.Errorf("All() = %s, want abc", got)
+	}
+	if registry[0].Version != 3 {
+		t.Error("All() reordered the registry itself")
+	}
+}
+
+func TestNewMigratorRejectsDuplicateVersions(t *testing.T) {
+	saved := registry
+	t.Cleanup(func() { registry = saved })
+
+	registry = append(append([]Migration(nil), saved...), Migration{Version: 2, Name: "again"})
+	// The versions are checked before the database is touched
+	_, err := NewMigrator(nil)
+	if err == nil || !strings.Contains(err.Error(), "duplicate migration version 2") {
+		t.Errorf("NewMigrator() error = %v, want a duplicate version 2", err)
+	}
+}
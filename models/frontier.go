@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// FrontierEntry is one URL of a crawl. Scope names the crawl it belongs to,
+// so an interrupted crawl can pick up its own entries on the next run.
+type FrontierEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Scope     string    `json:"scope" gorm:"not null;uniqueIndex:idx_frontier_entries_scope_url"`
+	URL       string    `json:"url" gorm:"not null;uniqueIndex:idx_frontier_entries_scope_url"`
+	Depth     int       `json:"depth"`
+	Status    string    `json:"status" gorm:"not null"`
+	Attempts  int       `json:"attempts" gorm:"default:0"`
+	LastError string    `json:"last_error"`
+	Data      *string   `json:"data" gorm:"type:jsonb"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,6 +1,15 @@
 package models
 
+// Image is one picture of a product's gallery. Width and Height come from
+// the CDN resize path and are 0 when the URL has none. Path and Hash are
+// set when the image was downloaded.
 type Image struct {
-    ID  uint   `gorm:"primarykey"`
-    URL string `gorm:"not null"`
-}
\ No newline at end of file
+    ID        uint   `json:"-" gorm:"primarykey"`
+    ProductID int    `json:"productId" gorm:"index;not null"`
+    Position  int    `json:"position"` // 0 is the main image
+    URL       string `json:"url" gorm:"not null;uniqueIndex"`
+    Width     int    `json:"width"`
+    Height    int    `json:"height"`
+    Path      string `json:"path,omitempty"` // local copy, relative to scraper.images.dir
+    Hash      string `json:"hash,omitempty"` // SHA-256 of the content
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount in minor units of its currency. Amounts are
+// kept in hundredths, the minor unit of every currency the storefronts
+// price in, and stored in NUMERIC(14,2) columns.
+//
+// In JSON a Money is a plain decimal number such as 12.28; the currency is
+// a field of the enclosing object, which sets it again after decoding (see
+// Price.UnmarshalJSON and Product.AfterFind).
+type Money struct {
+	Amount   int64  // minor units, e.g. 1228 for 12.28
+	Currency string // ISO 4217 code; may be empty until the enclosing object sets it
+}
+
+const minorUnits = 100
+
+// ParseMoney reads a decimal amount such as "12.28", "-3" or "1299.9".
+// Digits past the minor unit are rounded half away from zero. Amounts whose
+// minor units do not fit an int64 are an error.
+func ParseMoney(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	digits := s
+	if strings.HasPrefix(digits, "+") || strings.HasPrefix(digits, "-") {
+		digits = digits[1:]
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole+frac == "" || !isDigits(whole) || !isDigits(frac) {
+		// Exponent notation is rare enough to go through a float
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) {
+			return Money{}, fmt.Errorf("invalid amount %q", s)
+		}
+		if math.Abs(f*minorUnits) >= math.MaxInt64 {
+			return Money{}, fmt.Errorf("amount %q out of range", s)
+		}
+		return MoneyFromFloat(f, currency), nil
+	}
+
+	var units int64
+	if whole != "" {
+		var err error
+		if units, err = strconv.ParseInt(whole, 10, 64); err != nil {
+			return Money{}, fmt.Errorf("amount %q out of range", s)
+		}
+	}
+	frac += "000"
+	cents := int64(frac[0]-'0')*10 + int64(frac[1]-'0')
+	if frac[2] >= '5' {
+		cents++
+	}
+
+	if units > (math.MaxInt64-cents)/minorUnits {
+		return Money{}, fmt.Errorf("amount %q out of range", s)
+	}
+	amount := units*minorUnits + cents
+	if strings.HasPrefix(s, "-") {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MoneyFromFloat rounds f to the nearest minor unit.
+func MoneyFromFloat(f float64, currency string) Money {
+	return Money{Amount: int64(math.Round(f * minorUnits)), Currency: currency}
+}
+
+// Decimal formats the amount without currency, such as "12.28".
+func (m Money) Decimal() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/minorUnits, amount%minorUnits)
+}
+
+// String formats the amount with its currency, such as "12.28 TRY".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+// Float64 is the amount in major units, for ratios and display only.
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / minorUnits
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Less compares amounts; the caller makes sure the currencies match.
+func (m Money) Less(o Money) bool {
+	return m.Amount < o.Amount
+}
+
+// Mul scales the amount by f, rounding to the minor unit.
+func (m Money) Mul(f float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * f)), Currency: m.Currency}
+}
+
+// WithCurrency returns m in currency, keeping the amount.
+func (m Money) WithCurrency(currency string) Money {
+	m.Currency = currency
+	return m
+}
+
+// MarshalJSON writes the amount as a decimal number.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON reads a number, a numeric string or null, keeping the
+// digits exactly as written.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" || s == "" {
+		m.Amount = 0
+		return nil
+	}
+	parsed, err := ParseMoney(s, m.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value implements driver.Valuer for NUMERIC columns.
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns. The currency is set by
+// the enclosing model.
+func (m *Money) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		m.Amount = 0
+		return nil
+	case string:
+		return m.scanDecimal(v)
+	case []byte:
+		return m.scanDecimal(string(v))
+	case float64:
+		m.Amount = MoneyFromFloat(v, "").Amount
+		return nil
+	case int64:
+		m.Amount = v * minorUnits
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", value)
+	}
+}
+
+func (m *Money) scanDecimal(s string) error {
+	parsed, err := ParseMoney(s, m.Currency)
+	if err != nil {
+		return err
+	}
+	m.Amount = parsed.Amount
+	return nil
+}
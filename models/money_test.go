@@ -0,0 +1,217 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "12.28", want: 1228},
+		{in: "12.2", want: 1220},
+		{in: "12", want: 1200},
+		{in: "12.", want: 1200},
+		{in: ".5", want: 50},
+		{in: "  7.10 ", want: 710},
+		{in: "+3", want: 300},
+		{in: "-3", want: -300},
+		{in: "-0.05", want: -5},
+		{in: "1299.994", want: 129999},
+		{in: "1299.995", want: 130000},
+		{in: "0.005", want: 1},
+		{in: "-0.005", want: -1},
+		{in: "-1.005", want: -101},
+		{in: "92233720368547758.07", want: 9223372036854775807},
+		{in: "-92233720368547758.07", want: -9223372036854775807},
+		{in: "92233720368547758.08", wantErr: true},
+		{in: "92233720368547758.075", wantErr: true},
+		{in: "92233720368547759", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+		{in: "1e30", wantErr: true},
+		{in: "-1e17", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "Inf", wantErr: true},
+		{in: "1e3", want: 100000},
+		{in: "-1.5e-1", want: -15},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "--5", wantErr: true},
+		{in: "+-5", wantErr: true},
+		{in: "-+5", wantErr: true},
+		{in: "++5", wantErr: true},
+		{in: "- 5", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "12,28", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in, "TRY")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", tt.in, err)
+			continue
+		}
+		if got.Amount != tt.want || got.Currency != "TRY" {
+			t.Errorf("ParseMoney(%q) = %d %s, want %d TRY", tt.in, got.Amount, got.Currency, tt.want)
+		}
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{1228, "12.28"},
+		{-130000, "-1300.00"},
+	}
+	for _, tt := range tests {
+		m := Money{Amount: tt.amount, Currency: "TRY"}
+		if got := m.Decimal(); got != tt.want {
+			t.Errorf("Money{%d}.Decimal() = %q, want %q", tt.amount, got, tt.want)
+		}
+		if got := m.String(); got != tt.want+" TRY" {
+			t.Errorf("Money{%d}.String() = %q, want %q", tt.amount, got, tt.want+" TRY")
+		}
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want int64
+	}{
+		{12.28, 1228}, // 1227.9999999999998 before rounding
+		{0.1 + 0.2, 30},
+		{-12.28, -1228},
+		{0.005, 1},
+		{-0.005, -1},
+	}
+	for _, tt := range tests {
+		if got := MoneyFromFloat(tt.in, "").Amount; got != tt.want {
+			t.Errorf("MoneyFromFloat(%v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	encoded, err := json.Marshal(struct {
+		Price Money `json:"price"`
+		Loss  Money `json:"loss"`
+	}{Money{Amount: 1228, Currency: "TRY"}, Money{Amount: -5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(encoded), `{"price":12.28,"loss":-0.05}`; got != want {
+		t.Errorf("Marshal = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: `12.28`, want: 1228},
+		{in: `"12.28"`, want: 1228},
+		{in: `-0.05`, want: -5},
+		{in: `12.285`, want: 1229},
+		{in: `1.5e2`, want: 15000},
+		{in: `null`, want: 0},
+		{in: `""`, want: 0},
+		{in: `"twelve"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		m := Money{Amount: 99, Currency: "AED"}
+		err := json.Unmarshal([]byte(tt.in), &m)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %v, want an error", tt.in, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if m.Amount != tt.want || m.Currency != "AED" {
+			t.Errorf("Unmarshal(%s) = %d %s, want %d AED", tt.in, m.Amount, m.Currency, tt.want)
+		}
+	}
+}
+
+func TestMoneyScanValue(t *testing.T) {
+	tests := []struct {
+		in      any
+		want    int64
+		wantErr bool
+	}{
+		{in: nil, want: 0},
+		{in: "12.28", want: 1228},
+		{in: []byte("-0.50"), want: -50},
+		{in: "1299.995", want: 130000},
+		{in: 12.28, want: 1228},
+		{in: -0.005, want: -1},
+		{in: int64(3), want: 300},
+		{in: int64(-3), want: -300},
+		{in: "n/a", wantErr: true},
+		{in: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		m := Money{Amount: 99, Currency: "TRY"}
+		err := m.Scan(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Scan(%#v) = %v, want an error", tt.in, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Scan(%#v): %v", tt.in, err)
+			continue
+		}
+		if m.Amount != tt.want || m.Currency != "TRY" {
+			t.Errorf("Scan(%#v) = %d %s, want %d TRY", tt.in, m.Amount, m.Currency, tt.want)
+		}
+
+		// Value writes what Scan reads back
+		v, err := m.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Money
+		if err := back.Scan(v); err != nil || back.Amount != m.Amount {
+			t.Errorf("Scan(Value()) of %d = %d, %v", m.Amount, back.Amount, err)
+		}
+	}
+}
+
+func TestMoneyMul(t *testing.T) {
+	tests := []struct {
+		amount int64
+		f      float64
+		want   int64
+	}{
+		{1000, 0.9, 900},
+		{999, 0.5, 500},   // 499.5 rounds away from zero
+		{-999, 0.5, -500}, // and so does -499.5
+		{1228, 1, 1228},
+	}
+	for _, tt := range tests {
+		got := Money{Amount: tt.amount, Currency: "TRY"}.Mul(tt.f)
+		if got.Amount != tt.want || got.Currency != "TRY" {
+			t.Errorf("Money{%d}.Mul(%v) = %v, want %d TRY", tt.amount, tt.f, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// OutboxEvent is a message written in the same transaction as the state
+// change it describes, and published to the bus later by the outbox relay.
+type OutboxEvent struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Topic       string     `json:"topic" gorm:"not null"`
+	Key         string     `json:"key"`
+	Payload     string     `json:"payload" gorm:"type:jsonb;not null"`
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	LastError   string     `json:"last_error"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+}
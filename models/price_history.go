@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PriceHistory is one observation of a product's price. Every scrape of a
+// product records one, whether the price moved or not; Changed tells the
+// two apart, and unchanged observations act as heartbeats showing the
+// price still held at RecordedAt.
+type PriceHistory struct {
+	ID              uint      `json:"-" gorm:"primaryKey"`
+	ProductID       int       `json:"productId" gorm:"not null;index:idx_price_histories_product_recorded,priority:1"`
+	SellingPrice    Money     `json:"sellingPrice" gorm:"type:numeric(14,2)"`
+	DiscountedPrice Money     `json:"discountedPrice" gorm:"type:numeric(14,2)"` // the price paid
+	OriginalPrice   Money     `json:"originalPrice" gorm:"type:numeric(14,2)"`
+	Currency        string    `json:"currency"`
+	PromotionID     int       `json:"promotionId,omitempty"` // first promotion of the product, if any
+	PromotionName   string    `json:"promotionName,omitempty"`
+	OnFlashSale     bool      `json:"onFlashSale,omitempty"`
+	Changed         bool      `json:"changed"` // false for a heartbeat
+	RecordedAt      time.Time `json:"recordedAt" gorm:"autoCreateTime;index:idx_price_histories_product_recorded,priority:2"`
+}
+
+// ObservePrice returns the current price of product as an observation;
+// changed says whether it differs from the one recorded before.
+func ObservePrice(product Product, changed bool) PriceHistory {
+	h := PriceHistory{
+		ProductID:       product.ID,
+		SellingPrice:    product.Price.SellingPrice,
+		DiscountedPrice: product.Price.DiscountedPrice,
+		OriginalPrice:   product.Price.OriginalPrice,
+		Currency:        product.Price.Currency,
+		OnFlashSale:     product.OnFlashSale,
+		Changed:         changed,
+	}
+	if len(product.Promotions) > 0 {
+		h.PromotionID = product.Promotions[0].ID
+		h.PromotionName = product.Promotions[0].Name
+	}
+	return h
+}
+
+// SamePrice reports whether h records the same price as o, ignoring when
+// and for which product.
+func (h PriceHistory) SamePrice(o PriceHistory) bool {
+	return h.SellingPrice == o.SellingPrice &&
+		h.DiscountedPrice == o.DiscountedPrice &&
+		h.OriginalPrice == o.OriginalPrice &&
+		h.Currency == o.Currency &&
+		h.PromotionID == o.PromotionID &&
+		h.OnFlashSale == o.OnFlashSale
+}
+
+// UnmarshalJSON gives the amounts the currency of the observation.
+func (h *PriceHistory) UnmarshalJSON(b []byte) error {
+	type plain PriceHistory
+	if err := json.Unmarshal(b, (*plain)(h)); err != nil {
+		return err
+	}
+	h.setCurrency()
+	return nil
+}
+
+// AfterFind restores the currency of the amounts, which their columns do
+// not hold.
+func (h *PriceHistory) AfterFind(tx *gorm.DB) error {
+	h.setCurrency()
+	return nil
+}
+
+func (h *PriceHistory) setCurrency() {
+	h.SellingPrice = h.SellingPrice.WithCurrency(h.Currency)
+	h.DiscountedPrice = h.DiscountedPrice.WithCurrency(h.Currency)
+	h.OriginalPrice = h.OriginalPrice.WithCurrency(h.Currency)
+}
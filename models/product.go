@@ -2,8 +2,12 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // CustomTime is a custom time type that can handle time strings without timezone
@@ -12,7 +16,8 @@ type CustomTime time.Time
 // UnmarshalJSON implements json.Unmarshaler interface
 func (ct *CustomTime) UnmarshalJSON(b []byte) error {
 	s := strings.Trim(string(b), `"`) // Remove quotes
-	if s == "null" || s == "" {
+	// {} is how CustomTime was written before it had MarshalJSON
+	if s == "null" || s == "" || s == "{}" {
 		*ct = CustomTime(time.Time{})
 		return nil
 	}
@@ -31,6 +36,15 @@ func (ct *CustomTime) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// MarshalJSON implements json.Marshaler so values survive a round trip
+func (ct CustomTime) MarshalJSON() ([]byte, error) {
+	t := time.Time(ct)
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return t.MarshalJSON()
+}
+
 // Time returns the time.Time representation
 func (ct CustomTime) Time() time.Time {
 	return time.Time(ct)
@@ -43,15 +57,79 @@ type Product struct {
     Brand       string    `json:"brand"`
     BrandID     int       `json:"brandId"`
     MerchantID  int       `json:"merchantId"`
-    CategoryID  int       `json:"categoryId"`
+    CategoryID  *string   `json:"categoryId"` // see Category.ID
+    Category    *Category `json:"-" gorm:"foreignKey:CategoryID"`
     ImageURL    string    `json:"image"`
     Rating      Rating    `json:"ratingScore" gorm:"embedded"`
     Price       Price     `json:"price" gorm:"embedded"`
     Promotions  []Promotion `json:"promotions" gorm:"serializer:json"`
     SocialProof []SocialProof `json:"socialProof" gorm:"serializer:json"`
+    // Listing payload fields; Raw keeps the whole item as the site sent it
+    WebBrand               string      `json:"webBrand"`
+    BoutiqueID             int         `json:"boutiqueId"`
+    ItemNumber             int64       `json:"itemNumber"`
+    ListingID              string      `json:"listingId"`
+    DiscountPercentage     float64     `json:"discountPercentage"`
+    RecommendedRetailPrice RetailPrice `json:"recommendedRetailPrice" gorm:"embedded;embeddedPrefix:rrp_"`
+    BinaryPrice            BinaryPrice `json:"binaryPrice" gorm:"serializer:json"`
+    Stamps                 Stamps      `json:"stamps" gorm:"serializer:json"`
+    FlashSale              FlashSale   `json:"flashSale" gorm:"serializer:json"`
+    OnFlashSale            bool        `json:"onFlashSale"` // see FlashSale.Active
+    HasCollectableCoupon   bool        `json:"hasCollectableCoupon"`
+    Advert                 Advert      `json:"advert" gorm:"serializer:json"`
+    Raw                    json.RawMessage `json:"raw,omitempty" gorm:"serializer:json"`
+    Variants    []Variant `json:"variants,omitempty" gorm:"foreignKey:ProductID"` // saved with StorageHandler.SaveVariants
+    Images      []Image   `json:"images,omitempty" gorm:"foreignKey:ProductID"`   // saved with StorageHandler.SaveImages
     IsActive    bool      `json:"isActive" gorm:"default:true"`
     CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
     UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+    // Replayed is set on products read back from the checkpoint of an
+    // earlier run; their price may be days old
+    Replayed    bool      `json:"-" gorm:"-"`
+}
+
+// UnmarshalJSON also accepts a numeric categoryId, which payloads and
+// snapshots written before category IDs became text carry; 0 means none.
+func (p *Product) UnmarshalJSON(b []byte) error {
+    type plain Product
+    aux := struct {
+        *plain
+        CategoryID json.RawMessage `json:"categoryId"`
+    }{plain: (*plain)(p)}
+    if err := json.Unmarshal(b, &aux); err != nil {
+        return err
+    }
+
+    p.CategoryID = nil
+    raw := strings.TrimSpace(string(aux.CategoryID))
+    switch {
+    case raw == "" || raw == "null":
+    case strings.HasPrefix(raw, `"`):
+        var id string
+        if err := json.Unmarshal(aux.CategoryID, &id); err != nil {
+            return err
+        }
+        if id != "" {
+            p.CategoryID = &id
+        }
+    default:
+        var n json.Number
+        if err := json.Unmarshal(aux.CategoryID, &n); err != nil {
+            return fmt.Errorf("invalid categoryId %s: %w", raw, err)
+        }
+        if id := n.String(); id != "0" {
+            p.CategoryID = &id
+        }
+    }
+    return nil
+}
+
+// AfterFind restores the currency of the amounts, which their columns do
+// not hold.
+func (p *Product) AfterFind(tx *gorm.DB) error {
+    p.Price.SetCurrency(p.Price.Currency)
+    p.RecommendedRetailPrice.SetCurrency(p.RecommendedRetailPrice.Currency)
+    return nil
 }
 
 type Rating struct {
@@ -60,10 +138,123 @@ type Rating struct {
 }
 
 type Price struct {
-    SellingPrice     float64 `json:"sellingPrice"`
-    DiscountedPrice  float64 `json:"discountedPrice"`
-    OriginalPrice    float64 `json:"originalPrice"`
-    Currency         string  `json:"currency"`
+    SellingPrice     Money  `json:"sellingPrice" gorm:"type:numeric(14,2)"`
+    DiscountedPrice  Money  `json:"discountedPrice" gorm:"type:numeric(14,2)"`
+    OriginalPrice    Money  `json:"originalPrice" gorm:"type:numeric(14,2)"`
+    Currency         string `json:"currency"`
+}
+
+// UnmarshalJSON gives every amount the currency of the price.
+func (p *Price) UnmarshalJSON(b []byte) error {
+    type plain Price
+    if err := json.Unmarshal(b, (*plain)(p)); err != nil {
+        return err
+    }
+    p.SetCurrency(p.Currency)
+    return nil
+}
+
+// SetCurrency sets the currency of the price and its amounts.
+func (p *Price) SetCurrency(currency string) {
+    p.Currency = currency
+    p.SellingPrice = p.SellingPrice.WithCurrency(currency)
+    p.DiscountedPrice = p.DiscountedPrice.WithCurrency(currency)
+    p.OriginalPrice = p.OriginalPrice.WithCurrency(currency)
+}
+
+// RetailPrice is the recommended retail price block of a listing item. The
+// site sends one of two shapes, so fields missing from one stay 0.
+type RetailPrice struct {
+    SuggestedPrice    Money  `json:"suggestedPriceNumerized" gorm:"type:numeric(14,2)"` // the recommended retail price
+    Price             Money  `json:"priceNumerized" gorm:"type:numeric(14,2)"`
+    SellingPrice      Money  `json:"sellingPriceNumerized" gorm:"type:numeric(14,2)"`
+    PromotionPrice    Money  `json:"discountedPromotionPriceNumerized" gorm:"type:numeric(14,2)"`
+    LowestRecentPrice Money  `json:"lowestRecentPriceNumerized" gorm:"type:numeric(14,2)"`
+    IsPriceDiscounted bool   `json:"isPriceDiscounted"`
+    Currency          string `json:"currency"`
+}
+
+// UnmarshalJSON gives every amount the currency of the block.
+func (r *RetailPrice) UnmarshalJSON(b []byte) error {
+    type plain RetailPrice
+    if err := json.Unmarshal(b, (*plain)(r)); err != nil {
+        return err
+    }
+    r.SetCurrency(r.Currency)
+    return nil
+}
+
+// SetCurrency sets the currency of the block and its amounts.
+func (r *RetailPrice) SetCurrency(currency string) {
+    r.Currency = currency
+    r.SuggestedPrice = r.SuggestedPrice.WithCurrency(currency)
+    r.Price = r.Price.WithCurrency(currency)
+    r.SellingPrice = r.SellingPrice.WithCurrency(currency)
+    r.PromotionPrice = r.PromotionPrice.WithCurrency(currency)
+    r.LowestRecentPrice = r.LowestRecentPrice.WithCurrency(currency)
+}
+
+// BinaryPrice is the sale and strikethrough price pair shown on a listing
+// card, as display strings.
+type BinaryPrice struct {
+    SalePrice              string `json:"salePrice"`
+    StrikethroughPrice     string `json:"strikethroughPrice"`
+    StrikethroughPriceType string `json:"strikethroughPriceType"` // e.g. sellingPrice or suggestedPrice
+    DiscountPercentage     string `json:"discountPercentage"`
+    DiscountType           string `json:"discountType"` // e.g. rrp or basketPromotion
+    Currency               string `json:"currency"`
+}
+
+// Stamps are the badges on a listing card, keyed by corner.
+type Stamps map[string][]Stamp
+
+type Stamp struct {
+    Type        string  `json:"type"`
+    ImageURL    string  `json:"imageUrl"`
+    Position    string  `json:"position"`
+    AspectRatio float64 `json:"aspectRatio"`
+    Priority    int     `json:"priority"`
+}
+
+// FlashSale is the flash sale block of a listing item, kept as sent.
+type FlashSale map[string]any
+
+// Active reports whether the block describes a running flash sale: any
+// field set besides newStructureEnabled, which every item has.
+func (f FlashSale) Active() bool {
+    for k, v := range f {
+        if k == "newStructureEnabled" {
+            continue
+        }
+        switch v := v.(type) {
+        case nil:
+        case bool:
+            if v {
+                return true
+            }
+        case float64:
+            if v != 0 {
+                return true
+            }
+        case string:
+            if v != "" {
+                return true
+            }
+        default:
+            return true
+        }
+    }
+    return false
+}
+
+// Advert describes a sponsored placement; AdvertID is empty for organic
+// results.
+type Advert struct {
+    AdvertID     string  `json:"advertId"`
+    AdvertSlot   int     `json:"advertSlot"`
+    AdScore      float64 `json:"adScore"`
+    CPC          float64 `json:"cpc"`
+    SortingScore float64 `json:"sortingScore"`
 }
 
 type Promotion struct {
@@ -77,10 +268,3 @@ type SocialProof struct {
     Key   string `json:"key"`
     Value string `json:"value"`
 }
-
-type PriceHistory struct {
-    ID        uint      `gorm:"primaryKey"`
-    ProductID string    `json:"productId"`
-    Price     float64   `json:"price"`
-    RecordedAt time.Time `json:"recordedAt" gorm:"autoCreateTime"`
-}
\ No newline at end of file
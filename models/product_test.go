@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProductCategoryIDJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string // "" for nil
+		wantErr bool
+	}{
+		{in: `{"id": 1, "categoryId": "kadin-elbise"}`, want: "kadin-elbise"},
+		{in: `{"id": 1, "categoryId": 524}`, want: "524"},
+		{in: `{"id": 1, "categoryId": "524"}`, want: "524"},
+		{in: `{"id": 1, "categoryId": 0}`},
+		{in: `{"id": 1, "categoryId": ""}`},
+		{in: `{"id": 1, "categoryId": null}`},
+		{in: `{"id": 1}`},
+		{in: `{"id": 1, "categoryId": true}`, wantErr: true},
+		{in: `{"id": 1, "categoryId": {}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var p Product
+		err := json.Unmarshal([]byte(tt.in), &p)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = nil error, want one", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		got := ""
+		if p.CategoryID != nil {
+			got = *p.CategoryID
+		}
+		if got != tt.want || (tt.want == "") != (p.CategoryID == nil) {
+			t.Errorf("Unmarshal(%s).CategoryID = %v, want %q", tt.in, p.CategoryID, tt.want)
+		}
+		if p.ID != 1 {
+			t.Errorf("Unmarshal(%s).ID = %d, want the other fields decoded too", tt.in, p.ID)
+		}
+	}
+}
+
+func TestProductJSONRoundTrip(t *testing.T) {
+	category := "524"
+	in := Product{ID: 7, Name: "Kılıf", CategoryID: &category}
+	in.Price.SetCurrency("TRY")
+	in.Price.DiscountedPrice = Money{Amount: 1228, Currency: "TRY"}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Product
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != 7 || out.Name != "Kılıf" || out.CategoryID == nil || *out.CategoryID != "524" {
+		t.Errorf("round trip = %+v", out)
+	}
+	// Price.UnmarshalJSON still runs for the embedded price
+	if out.Price.DiscountedPrice != (Money{Amount: 1228, Currency: "TRY"}) {
+		t.Errorf("discounted price = %v, want 12.28 TRY", out.Price.DiscountedPrice)
+	}
+}
@@ -1,11 +1,39 @@
 package models
 
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// Variant is one purchasable option of a product, such as a size or color.
+// SKU is the site's barcode or item number when the page has them.
 type Variant struct {
-	ID        uint   `gorm:"primaryKey"`
-	ProductID uint   `gorm:"index"`
-	SKU       string `gorm:"uniqueIndex"`
-	Name      string
-	Price     float64
-	Stock     int
-	Available bool
+	ID        uint   `json:"-" gorm:"primaryKey"`
+	ProductID int    `json:"productId" gorm:"index;not null"`
+	SKU       string `json:"sku" gorm:"uniqueIndex"`
+	Name      string `json:"name"`                            // e.g. "Beden: M"
+	Attribute string `json:"attribute"`                       // e.g. "Beden" or "Renk"; empty when the page does not say
+	Value     string `json:"value"`                           // e.g. "M" or "Pembe"
+	Price     Money  `json:"price" gorm:"type:numeric(14,2)"` // 0 when the page has no price per variant
+	Currency  string `json:"currency"`
+	Stock     int    `json:"stock"` // 0 when the site does not state the quantity
+	Available bool   `json:"available"`
+}
+
+// UnmarshalJSON gives the price the currency of the variant.
+func (v *Variant) UnmarshalJSON(b []byte) error {
+	type plain Variant
+	if err := json.Unmarshal(b, (*plain)(v)); err != nil {
+		return err
+	}
+	v.Price = v.Price.WithCurrency(v.Currency)
+	return nil
+}
+
+// AfterFind restores the currency of the price, which its column does not
+// hold.
+func (v *Variant) AfterFind(tx *gorm.DB) error {
+	v.Price = v.Price.WithCurrency(v.Currency)
+	return nil
 }
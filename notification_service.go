@@ -1,57 +1,83 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"trendyol-scraper/messaging"
 	"trendyol-scraper/models"
 
-	"github.com/IBM/sarama"
 	"gorm.io/gorm"
 )
 
 type NotificationService struct {
-	db *gorm.DB
+	db         *gorm.DB
+	subscriber messaging.Subscriber
+	topic      string
 }
 
 type PriceDropMessage struct {
-	ProductID   int      `json:"productId"`
-	ProductName string   `json:"productName"`
-	OldPrice    float64  `json:"oldPrice"`
-	NewPrice    float64  `json:"newPrice"`
-	Currency    string   `json:"currency"`
-	ImageURL    string   `json:"imageUrl"`
-	UserIDs     []string `json:"userIds"`
+	ProductID   int          `json:"productId"`
+	ProductName string       `json:"productName"`
+	OldPrice    models.Money `json:"oldPrice"`
+	NewPrice    models.Money `json:"newPrice"`
+	Currency    string       `json:"currency"`
+	ImageURL    string       `json:"imageUrl"`
+	UserIDs     []string     `json:"userIds"`
+	// From the listing; zero when the product was not scraped from one
+	SuggestedPrice       models.Money `json:"suggestedPrice"`
+	OnFlashSale          bool         `json:"onFlashSale,omitempty"`
+	HasCollectableCoupon bool         `json:"hasCollectableCoupon,omitempty"`
 }
 
-func (ns *NotificationService) StartConsumer() {
-	config := sarama.NewConfig()
-	consumer, err := sarama.NewConsumer([]string{"localhost:9092"}, config)
-	if err != nil {
-		log.Fatalf("Failed to create consumer: %v", err)
+// UnmarshalJSON gives the amounts the currency of the message.
+func (m *PriceDropMessage) UnmarshalJSON(b []byte) error {
+	type plain PriceDropMessage
+	if err := json.Unmarshal(b, (*plain)(m)); err != nil {
+		return err
 	}
+	m.OldPrice = m.OldPrice.WithCurrency(m.Currency)
+	m.NewPrice = m.NewPrice.WithCurrency(m.Currency)
+	m.SuggestedPrice = m.SuggestedPrice.WithCurrency(m.Currency)
+	return nil
+}
 
-	partitionConsumer, err := consumer.ConsumePartition("price-drops", 0, sarama.OffsetNewest)
-	if err != nil {
-		log.Fatalf("Failed to create partition consumer: %v", err)
+// details describes the listing extras of the drop, such as ", flash sale",
+// or returns "" when there are none.
+func (m PriceDropMessage) details() string {
+	var parts []string
+	if m.NewPrice.Less(m.SuggestedPrice) {
+		parts = append(parts, fmt.Sprintf("%.0f%% below the recommended %s", 100*(1-m.NewPrice.Float64()/m.SuggestedPrice.Float64()), m.SuggestedPrice))
+	}
+	if m.OnFlashSale {
+		parts = append(parts, "flash sale")
+	}
+	if m.HasCollectableCoupon {
+		parts = append(parts, "coupon available")
+	}
+	if len(parts) == 0 {
+		return ""
 	}
+	return ", " + strings.Join(parts, ", ")
+}
 
-	for {
-		select {
-		case msg := <-partitionConsumer.Messages():
-			var priceDrop PriceDropMessage
-			if err := json.Unmarshal(msg.Value, &priceDrop); err != nil {
-				log.Printf("Failed to parse price drop message: %v", err)
-				continue
-			}
+// StartConsumer subscribes to the price drop topic and blocks until ctx is
+// cancelled or the subscriber is closed.
+func (ns *NotificationService) StartConsumer(ctx context.Context) error {
+	return ns.subscriber.Subscribe(ctx, ns.topic, ns.handleMessage)
+}
 
-			if err := ns.sendNotifications(priceDrop); err != nil {
-				log.Printf("Failed to send notifications: %v", err)
-			}
-		case err := <-partitionConsumer.Errors():
-			log.Printf("Consumer error: %v", err)
-		}
+func (ns *NotificationService) handleMessage(ctx context.Context, msg messaging.Message) error {
+	var priceDrop PriceDropMessage
+	if err := json.Unmarshal(msg.Value, &priceDrop); err != nil {
+		// A malformed message will never succeed; acknowledge and skip it
+		log.Printf("Failed to parse price drop message: %v", err)
+		return nil
 	}
+
+	return ns.sendNotifications(priceDrop)
 }
 
 func (ns *NotificationService) sendNotifications(msg PriceDropMessage) error {
@@ -61,13 +87,13 @@ func (ns *NotificationService) sendNotifications(msg PriceDropMessage) error {
 		// 2. Send push notification/email/SMS based on preferences
 		// 3. Log the notification
 
-		log.Printf("Sending price drop notification to user %s for product %s (%s%.2f -> %s%.2f)",
-			userID, msg.ProductName, msg.Currency, msg.OldPrice, msg.Currency, msg.NewPrice)
+		log.Printf("Sending price drop notification to user %s for product %s (%s -> %s%s)",
+			userID, msg.ProductName, msg.OldPrice, msg.NewPrice, msg.details())
 
 		// Log notification in database
 		notification := models.Notification{
 			ProductID: msg.ProductID, // Already an int
-			Message:   fmt.Sprintf("Price dropped from %s%.2f to %s%.2f", msg.Currency, msg.OldPrice, msg.Currency, msg.NewPrice),
+			Message:   fmt.Sprintf("Price dropped from %s to %s%s", msg.OldPrice, msg.NewPrice, msg.details()),
 			Type:      "price_drop",
 			Sent:      true,
 		}
@@ -77,4 +103,4 @@ func (ns *NotificationService) sendNotifications(msg PriceDropMessage) error {
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
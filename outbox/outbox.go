@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"trendyol-scraper/messaging"
+	"trendyol-scraper/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Enqueue stores msg in the outbox using tx, so the event is only visible to
+// the relay if the surrounding transaction commits.
+func Enqueue(tx *gorm.DB, msg messaging.Message) error {
+	event := models.OutboxEvent{
+		Topic:   msg.Topic,
+		Key:     string(msg.Key),
+		Payload: string(msg.Value),
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to enqueue %s event: %w", msg.Topic, err)
+	}
+	return nil
+}
+
+// Relay publishes pending outbox events in insertion order and marks them
+// delivered. Several relays may run at once; rows are claimed with
+// SKIP LOCKED so each event is handled by one relay at a time.
+type Relay struct {
+	db           *gorm.DB
+	publisher    messaging.Publisher
+	batchSize    int
+	pollInterval time.Duration
+}
+
+func NewRelay(db *gorm.DB, publisher messaging.Publisher, batchSize int, pollInterval time.Duration) *Relay {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+	return &Relay{
+		db:           db,
+		publisher:    publisher,
+		batchSize:    batchSize,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Outbox relay: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes pending events until the outbox is empty or a publish
+// fails, and returns how many events were delivered.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := r.relayBatch(ctx)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < r.batchSize {
+			return total, nil
+		}
+	}
+}
+
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	delivered := 0
+	var publishErr error
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var events []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("delivered_at IS NULL").
+			Order("id").
+			Limit(r.batchSize).
+			Find(&events).Error; err != nil {
+			return fmt.Errorf("failed to load pending outbox events: %w", err)
+		}
+
+		for _, event := range events {
+			msg := messaging.Message{
+				Topic: event.Topic,
+				Key:   []byte(event.Key),
+				Value: []byte(event.Payload),
+			}
+
+			if err := r.publisher.Publish(ctx, msg); err != nil {
+				// Stop at the first failure so events keep their order
+				publishErr = fmt.Errorf("failed to publish outbox event %d: %w", event.ID, err)
+				return tx.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+					"attempts":   gorm.Expr("attempts + 1"),
+					"last_error": err.Error(),
+				}).Error
+			}
+
+			now := time.Now().UTC()
+			if err := tx.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+				"attempts":     gorm.Expr("attempts + 1"),
+				"delivered_at": now,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to mark outbox event %d delivered: %w", event.ID, err)
+			}
+			delivered++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return delivered, publishErr
+}
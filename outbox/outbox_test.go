@@ -0,0 +1,157 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"trendyol-scraper/internal/dbtest"
+	"trendyol-scraper/messaging"
+	"trendyol-scraper/models"
+
+	"gorm.io/gorm"
+)
+
+// recordingPublisher keeps what was published and fails the messages
+// whose value is in fail.
+type recordingPublisher struct {
+	mu        sync.Mutex
+	published []string
+	fail      map[string]bool
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, msg messaging.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail[string(msg.Value)] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, string(msg.Value))
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func enqueue(t *testing.T, db *gorm.DB, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		if err := Enqueue(db, messaging.Message{Topic: "price-drops", Key: []byte(fmt.Sprint(i)), Value: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func outboxEvents(t *testing.T, db *gorm.DB) []models.OutboxEvent {
+	t.Helper()
+	var events []models.OutboxEvent
+	if err := db.Order("id").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestRelayFlush(t *testing.T) {
+	db := dbtest.Open(t)
+	enqueue(t, db, 5)
+	publisher := &recordingPublisher{}
+	relay := NewRelay(db, publisher, 2, 0)
+
+	n, err := relay.Flush(context.Background())
+	if err != nil || n != 5 {
+		t.Fatalf("Flush() = %d, %v; want all 5 events", n, err)
+	}
+	if got := strings.Join(publisher.published, " "); got != "1 2 3 4 5" {
+		t.Errorf("published %s, want the events in insertion order", got)
+	}
+	for _, e := range outboxEvents(t, db) {
+		if e.DeliveredAt == nil || e.Attempts != 1 {
+			t.Errorf("event %d: delivered at %v after %d attempts, want delivered after one", e.ID, e.DeliveredAt, e.Attempts)
+		}
+	}
+
+	if n, err := relay.Flush(context.Background()); err != nil || n != 0 {
+		t.Errorf("second Flush() = %d, %v; want nothing left", n, err)
+	}
+}
+
+func TestRelayPublishFailure(t *testing.T) {
+	db := dbtest.Open(t)
+	enqueue(t, db, 5)
+	publisher := &recordingPublisher{fail: map[string]bool{"3": true}}
+	relay := NewRelay(db, publisher, 10, 0)
+
+	n, err := relay.Flush(context.Background())
+	if err == nil || n != 2 {
+		t.Fatalf("Flush() = %d, %v; want 2 delivered and the failure", n, err)
+	}
+	events := outboxEvents(t, db)
+	if e := events[2]; e.DeliveredAt != nil || e.Attempts != 1 || !strings.Contains(e.LastError, "broker unavailable") {
+		t.Errorf("failed event = %+v, want it pending with the attempt and error recorded", e)
+	}
+	for _, e := range events[3:] {
+		if e.DeliveredAt != nil || e.Attempts != 0 {
+			t.Errorf("event %d after the failure was touched: %+v", e.ID, e)
+		}
+	}
+
+	// Once the broker is back the remaining events follow in order
+	publisher.fail = nil
+	if n, err := relay.Flush(context.Background()); err != nil || n != 3 {
+		t.Fatalf("Flush() = %d, %v; want the remaining 3", n, err)
+	}
+	if got := strings.Join(publisher.published, " "); got != "1 2 3 4 5" {
+		t.Errorf("published %s, want the events in insertion order", got)
+	}
+	if e := outboxEvents(t, db)[2]; e.DeliveredAt == nil || e.Attempts != 2 {
+		t.Errorf("retried event = %+v, want delivered after two attempts", e)
+	}
+}
+
+func TestConcurrentRelays(t *testing.T) {
+	db := dbtest.Open(t)
+	enqueue(t, db, 50)
+	publisher := &recordingPublisher{}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := NewRelay(db, publisher, 5, 0).Flush(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, v := range publisher.published {
+		if seen[v] {
+			t.Errorf("event %s published twice", v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != 50 {
+		t.Errorf("%d events published, want 50", len(seen))
+	}
+}
+
+// Enqueue runs inside the caller's transaction; this only builds its SQL.
+func TestEnqueueSQL(t *testing.T) {
+	db, statements := dbtest.DryRun(t)
+	tx := db.Session(&gorm.Session{SkipDefaultTransaction: true})
+	if err := Enqueue(tx, messaging.Message{Topic: "price-drops", Key: []byte("7"), Value: []byte(`{"productId":7}`)}); err != nil {
+		t.Fatal(err)
+	}
+	sql := statements()
+	if len(sql) != 1 || !strings.HasPrefix(sql[0], `INSERT INTO "outbox_events"`) {
+		t.Fatalf("statements = %q, want one insert", sql)
+	}
+	for _, want := range []string{"'price-drops'", "'7'", `'{"productId":7}'`} {
+		if !strings.Contains(sql[0], want) {
+			t.Errorf("%s\ndoes not contain %s", sql[0], want)
+		}
+	}
+}
@@ -3,78 +3,188 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"math/rand"
 	"strconv"
 	"time"
+	"trendyol-scraper/messaging"
 	"trendyol-scraper/models"
+	"trendyol-scraper/outbox"
 	"trendyol-scraper/storage"
 
-	"github.com/IBM/sarama"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductAnalysisService struct {
 	db             *gorm.DB
 	storageHandler storage.StorageHandler
-	kafkaProducer  sarama.SyncProducer
+	topic          string
+	// priceHistory is set when products are stored in the database, which
+	// price_histories rows reference. File storage derives the history
+	// from its snapshots instead.
+	priceHistory bool
 }
 
 func (s *ProductAnalysisService) ProcessProducts(ctx context.Context, products []models.Product) error {
+	var variants []models.Variant
+	var images []models.Image
+	var snapshot []models.Product // new products, with file storage
+	categories := make(map[string]bool) // whose product counts change
 	for _, product := range products {
+		if err := ctx.Err(); err != nil {
+			// The new products handled so far are still written
+			return errors.Join(err, s.saveSnapshot(snapshot))
+		}
+		if product.CategoryID != nil {
+			categories[*product.CategoryID] = true
+		}
+
 		// Check if product exists
 		var existingProduct models.Product
-		result := s.db.Where("id = ?", product.ID).First(&existingProduct)
+		result := s.db.WithContext(ctx).Where("id = ?", product.ID).First(&existingProduct)
 
 		if result.Error == gorm.ErrRecordNotFound {
-			// New product
-			if err := s.storageHandler.SaveProducts([]models.Product{product}); err != nil {
-				log.Printf("Failed to find existing product %d: %v", product.ID, err)
+			// New product, saved together with its first price observation;
+			// file storage writes the new products of the batch as one
+			// snapshot below
+			if !s.priceHistory {
+				snapshot = append(snapshot, product)
+			} else if err := s.saveNewProduct(ctx, product); err != nil {
+				log.Printf("Failed to save new product %d: %v", product.ID, err)
 				continue
 			}
 			log.Printf("New product inserted: %s", product.Name)
+			variants = append(variants, product.Variants...)
+			images = append(images, product.Images...)
+		} else if result.Error == nil && product.Replayed {
+			// Read back from an earlier run's checkpoint: the stored product
+			// is at least as recent, so the old price is neither recorded
+			// nor compared
+			continue
 		} else if result.Error == nil {
-			// Existing product - check for price changes
-			if existingProduct.Price.DiscountedPrice != product.Price.DiscountedPrice {
-				// Price changed - log and check for drop
-				priceHistory := models.PriceHistory{
-					ProductID: strconv.Itoa(product.ID),
-					Price:     product.Price.DiscountedPrice,
-				}
-				
-				if err := s.db.Create(&priceHistory).Error; err != nil {
-					log.Printf("Failed to log price history for product %d: %v", product.ID, err)
+			// Existing product - record the price, queue a drop event and
+			// update the product in one transaction so an event is never
+			// lost or emitted for a change that was rolled back
+			err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				// Every scrape is recorded; unchanged prices as heartbeats
+				if s.priceHistory {
+					if _, err := storage.RecordPrice(tx, product); err != nil {
+						return fmt.Errorf("failed to log price history: %w", err)
+					}
 				}
 
-				// Check if price dropped
-				if product.Price.DiscountedPrice < existingProduct.Price.DiscountedPrice {
+				// Check if price dropped; amounts are exact, so any drop is
+				// real. A price in another currency starts afresh instead
+				newPrice, oldPrice := product.Price.DiscountedPrice, existingProduct.Price.DiscountedPrice
+				if newPrice.Currency == oldPrice.Currency && newPrice.Less(oldPrice) {
 					// Get users who favorited this product
 					var favoriteUsers []models.Favorite
-					if err := s.db.Where("product_id = ?", product.ID).Find(&favoriteUsers).Error; err != nil {
-						log.Printf("Failed to get favorite users for product %d: %v", product.ID, err)
-						continue
+					if err := tx.Where("product_id = ?", product.ID).Find(&favoriteUsers).Error; err != nil {
+						return fmt.Errorf("failed to get favorite users: %w", err)
 					}
 
 					if len(favoriteUsers) > 0 {
-						// Send price drop notification
-						s.sendPriceDropNotification(favoriteUsers, product, existingProduct.Price.DiscountedPrice)
+						if err := s.enqueuePriceDrop(tx, favoriteUsers, product, existingProduct.Price.DiscountedPrice); err != nil {
+							return err
+						}
 					}
 				}
-			}
 
-			// Update product
-			if err := s.db.Save(&product).Error; err != nil {
-				log.Printf("Failed to scrape product %d: %v", product.ID, err)
+				// Update product; its variants and images are saved below.
+				// Searches and uncategorized scrapes do not know the
+				// category, so the stored one is kept
+				if product.CategoryID == nil {
+					product.CategoryID = existingProduct.CategoryID
+				} else if existingProduct.CategoryID != nil {
+					categories[*existingProduct.CategoryID] = true
+				}
+				return tx.Omit(clause.Associations).Save(&product).Error
+			})
+			if err != nil {
+				log.Printf("Failed to update product %d: %v", product.ID, err)
+				continue
+			}
+			variants = append(variants, product.Variants...)
+			// Raw is only set on listing products, whose main image must
+			// not replace a stored gallery
+			if len(product.Raw) == 0 || !s.hasImages(ctx, product.ID) {
+				images = append(images, product.Images...)
 			}
 		} else {
 			log.Printf("Error checking product existence: %v", result.Error)
 		}
 	}
+
+	if err := s.saveSnapshot(snapshot); err != nil {
+		return err
+	}
+	// Only the browser scrapes variants and galleries. Listing products come
+	// with their main image alone, which is saved for products that have no
+	// images yet, so galleries scraped before are kept
+	if err := s.storageHandler.SaveVariants(variants); err != nil {
+		return fmt.Errorf("failed to save variants: %w", err)
+	}
+	if err := s.storageHandler.SaveImages(images); err != nil {
+		return fmt.Errorf("failed to save images: %w", err)
+	}
+	touched := make([]string, 0, len(categories))
+	for id := range categories {
+		touched = append(touched, id)
+	}
+	if err := storage.RefreshProductCountsFor(s.db.WithContext(ctx), touched); err != nil {
+		return fmt.Errorf("failed to count products per category: %w", err)
+	}
+	return nil
+}
+
+func (s *ProductAnalysisService) saveNewProduct(ctx context.Context, product models.Product) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Variants and images are saved by ProcessProducts
+		if err := tx.Omit(clause.Associations).Save(&product).Error; err != nil {
+			return err
+		}
+		if product.Replayed {
+			return nil // the price is not current, see ProcessProducts
+		}
+		if _, err := storage.RecordPrice(tx, product); err != nil {
+			return fmt.Errorf("failed to log price history: %w", err)
+		}
+		return nil
+	})
+}
+
+// hasImages reports whether images of the product are stored. File storage
+// keeps no image rows in the database, so there every existing product
+// counts as having them.
+func (s *ProductAnalysisService) hasImages(ctx context.Context, productID int) bool {
+	if !s.priceHistory {
+		return true
+	}
+	var n int64
+	if err := s.db.WithContext(ctx).Model(&models.Image{}).Where("product_id = ?", productID).Limit(1).Count(&n).Error; err != nil {
+		log.Printf("Failed to check images of product %d: %v", productID, err)
+		return true
+	}
+	return n > 0
+}
+
+// saveSnapshot writes the new products of a batch with file storage.
+func (s *ProductAnalysisService) saveSnapshot(products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	if err := s.storageHandler.SaveProducts(products); err != nil {
+		return fmt.Errorf("failed to save new products: %w", err)
+	}
 	return nil
 }
 
-func (s *ProductAnalysisService) sendPriceDropNotification(users []models.Favorite, product models.Product, oldPrice float64) {
-	// Prepare Kafka message
+// enqueuePriceDrop writes the price drop event to the outbox; the outbox
+// relay publishes it once the transaction has committed.
+func (s *ProductAnalysisService) enqueuePriceDrop(tx *gorm.DB, users []models.Favorite, product models.Product, oldPrice models.Money) error {
 	message := PriceDropMessage{
 		ProductID:   product.ID,
 		ProductName: product.Name,
@@ -83,35 +193,44 @@ func (s *ProductAnalysisService) sendPriceDropNotification(users []models.Favori
 		Currency:    product.Price.Currency,
 		ImageURL:    product.ImageURL,
 		UserIDs:     make([]string, len(users)),
+
+		SuggestedPrice:       product.RecommendedRetailPrice.SuggestedPrice,
+		OnFlashSale:          product.OnFlashSale,
+		HasCollectableCoupon: product.HasCollectableCoupon,
 	}
 
 	for i, fav := range users {
 		message.UserIDs[i] = fav.UserID
 	}
 
-	// Send to Kafka
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Failed to marshal price drop message: %v", err)
-		return
+		return fmt.Errorf("failed to marshal price drop message: %w", err)
 	}
 
-	msg := &sarama.ProducerMessage{
-		Topic: "price-drops",
-		Value: sarama.StringEncoder(messageBytes),
-	}
-
-	if _, _, err := s.kafkaProducer.SendMessage(msg); err != nil {
-		log.Printf("Failed to send price drop notification: %v", err)
-	}
+	return outbox.Enqueue(tx, messaging.Message{
+		Topic: s.topic,
+		Key:   []byte(strconv.Itoa(product.ID)),
+		Value: messageBytes,
+	})
 }
 
-func (s *ProductAnalysisService) PrioritizeFavoritedProducts() {
+// PrioritizeFavoritedProducts re-checks favorited products every minute
+// until ctx is cancelled.
+func (s *ProductAnalysisService) PrioritizeFavoritedProducts(ctx context.Context) error {
 	ticker := time.NewTicker(1 * time.Minute) // Check every minute
-	for range ticker.C {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
 		// Get all favorited products
 		var favorites []models.Favorite
-		if err := s.db.Find(&favorites).Error; err != nil {
+		if err := s.db.WithContext(ctx).Find(&favorites).Error; err != nil {
 			log.Printf("Failed to get favorited products: %v", err)
 			continue
 		}
@@ -124,10 +243,14 @@ func (s *ProductAnalysisService) PrioritizeFavoritedProducts() {
 
 		// Process these products with higher priority
 		for productID := range productIDs {
+			if ctx.Err() != nil {
+				return nil
+			}
+
 			// In a real implementation, we would fetch fresh data for this product
 			// For mock data, we'll just update the existing record
 			var product models.Product
-			if err := s.db.Where("id = ?", productID).First(&product).Error; err != nil {
+			if err := s.db.WithContext(ctx).Where("id = ?", productID).First(&product).Error; err != nil {
 				log.Printf("Failed to find product %d: %v", productID, err)
 				continue
 			}
@@ -136,9 +259,9 @@ func (s *ProductAnalysisService) PrioritizeFavoritedProducts() {
 			// In real implementation, this would come from fresh scraping
 			if rand.Intn(10) == 0 { // 10% chance of price change for demo
 				oldPrice := product.Price.DiscountedPrice
-				product.Price.DiscountedPrice = oldPrice * (0.9 + rand.Float64()*0.2) // Random price change ±10%
+				product.Price.DiscountedPrice = oldPrice.Mul(0.9 + rand.Float64()*0.2) // Random price change ±10%, rounded to the minor unit
 				
-				if err := s.ProcessProducts(context.Background(), []models.Product{product}); err != nil {
+				if err := s.ProcessProducts(ctx, []models.Product{product}); err != nil {
 					log.Printf("Failed to process prioritized product %d: %v", productID, err)
 				}
 			}
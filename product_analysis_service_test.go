@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"trendyol-scraper/config"
+	"trendyol-scraper/internal/dbtest"
+	"trendyol-scraper/models"
+	"trendyol-scraper/storage"
+
+	"gorm.io/gorm"
+)
+
+func testProduct(id int, price int64, currency string) models.Product {
+	p := models.Product{ID: id, Name: "Kılıf"}
+	p.Price.SellingPrice = models.Money{Amount: price}
+	p.Price.DiscountedPrice = models.Money{Amount: price}
+	p.Price.OriginalPrice = models.Money{Amount: price}
+	p.Price.SetCurrency(currency)
+	return p
+}
+
+func TestProcessProductsJSONSnapshot(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Scraper.JSONOutputPath = t.TempDir()
+	s := &ProductAnalysisService{db: dbtest.Open(t), storageHandler: storage.NewJSONStorage(cfg)}
+
+	products := []models.Product{testProduct(1, 1000, "TRY"), testProduct(2, 2000, "TRY"), testProduct(3, 3000, "TRY")}
+	if err := s.ProcessProducts(context.Background(), products); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(cfg.Scraper.JSONOutputPath, "products_*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d products files, want one snapshot for the batch", len(files))
+	}
+	list, err := s.storageHandler.ListProducts(storage.ProductFilter{}, storage.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 3 {
+		t.Errorf("%d products stored, want 3", list.Total)
+	}
+}
+
+func TestProcessProductsPriceDrop(t *testing.T) {
+	tests := []struct {
+		name       string
+		price      int64
+		currency   string
+		wantEvents int64
+	}{
+		{name: "drop", price: 900, currency: "TRY", wantEvents: 1},
+		{name: "rise", price: 1100, currency: "TRY"},
+		{name: "unchanged", price: 1000, currency: "TRY"},
+		{name: "lower amount in another currency", price: 300, currency: "AED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.Open(t)
+			s := &ProductAnalysisService{db: db, storageHandler: mustDatabaseStorage(t, db), topic: "price-drops", priceHistory: true}
+			ctx := context.Background()
+			if err := s.ProcessProducts(ctx, []models.Product{testProduct(1, 1000, "TRY")}); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Create(&models.Favorite{UserID: "u1", ProductID: 1}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.ProcessProducts(ctx, []models.Product{testProduct(1, tt.price, tt.currency)}); err != nil {
+				t.Fatal(err)
+			}
+			var events int64
+			if err := db.Model(&models.OutboxEvent{}).Count(&events).Error; err != nil {
+				t.Fatal(err)
+			}
+			if events != tt.wantEvents {
+				t.Errorf("%d price drop events, want %d", events, tt.wantEvents)
+			}
+			stored, err := s.storageHandler.GetProduct(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (models.Money{Amount: tt.price, Currency: tt.currency}); stored.Price.DiscountedPrice != want {
+				t.Errorf("stored price = %v, want %v", stored.Price.DiscountedPrice, want)
+			}
+		})
+	}
+}
+
+func TestProcessProductsSkipsReplayedPrices(t *testing.T) {
+	db := dbtest.Open(t)
+	s := &ProductAnalysisService{db: db, storageHandler: mustDatabaseStorage(t, db), topic: "price-drops", priceHistory: true}
+	ctx := context.Background()
+	if err := s.ProcessProducts(ctx, []models.Product{testProduct(1, 1000, "TRY")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Favorite{UserID: "u1", ProductID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// An old price read back from a checkpoint, and a new product
+	stale, fresh := testProduct(1, 500, "TRY"), testProduct(2, 700, "TRY")
+	stale.Replayed, fresh.Replayed = true, true
+	if err := s.ProcessProducts(ctx, []models.Product{stale, fresh}); err != nil {
+		t.Fatal(err)
+	}
+
+	var events, observations int64
+	db.Model(&models.OutboxEvent{}).Count(&events)
+	db.Model(&models.PriceHistory{}).Count(&observations)
+	if events != 0 || observations != 1 {
+		t.Errorf("%d events and %d price observations, want none beyond the first scrape", events, observations)
+	}
+	if p, err := s.storageHandler.GetProduct(1); err != nil || p.Price.DiscountedPrice.Amount != 1000 {
+		t.Errorf("stored product 1 = %+v, %v; want the stored price kept", p, err)
+	}
+	if _, err := s.storageHandler.GetProduct(2); err != nil {
+		t.Errorf("replayed new product not saved: %v", err)
+	}
+}
+
+func TestProcessProductsListingImage(t *testing.T) {
+	db := dbtest.Open(t)
+	s := &ProductAnalysisService{db: db, storageHandler: mustDatabaseStorage(t, db), priceHistory: true}
+	ctx := context.Background()
+
+	// Product 1 was scraped in the browser with its gallery, product 2 only
+	// from a listing before listing images were kept
+	scraped, listed := testProduct(1, 1000, "TRY"), testProduct(2, 2000, "TRY")
+	scraped.Images = []models.Image{{ProductID: 1, URL: "https://cdn.dsmcdn.com/1a.jpg"}, {ProductID: 1, Position: 1, URL: "https://cdn.dsmcdn.com/1b.jpg"}}
+	if err := s.ProcessProducts(ctx, []models.Product{scraped, listed}); err != nil {
+		t.Fatal(err)
+	}
+
+	var fromListing []models.Product
+	for _, id := range []int{1, 2, 3} {
+		p := testProduct(id, 1000, "TRY")
+		p.Raw = []byte(`{}`)
+		p.Images = []models.Image{{ProductID: id, URL: fmt.Sprintf("https://cdn.dsmcdn.com/listing-%d.jpg", id)}}
+		fromListing = append(fromListing, p)
+	}
+	if err := s.ProcessProducts(ctx, fromListing); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[int]int{1: 2, 2: 1, 3: 1} {
+		p, err := s.storageHandler.GetProduct(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Images) != want {
+			t.Errorf("product %d has images %+v, want %d", id, p.Images, want)
+		}
+	}
+}
+
+func TestProcessProductsCategoryCounts(t *testing.T) {
+	db := dbtest.Open(t)
+	ds := mustDatabaseStorage(t, db)
+	s := &ProductAnalysisService{db: db, storageHandler: ds, priceHistory: true}
+	ctx := context.Background()
+	kadin, elbise, erkek := "kadin", "kadin-elbise", "erkek"
+	err := ds.SaveCategories([]models.Category{
+		{ID: kadin, Children: []models.Category{{ID: elbise, ParentID: &kadin, IsLeaf: true}}},
+		{ID: erkek, IsLeaf: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product := testProduct(1, 1000, "TRY")
+	product.CategoryID = &elbise
+	if err := s.ProcessProducts(ctx, []models.Product{product}); err != nil {
+		t.Fatal(err)
+	}
+	// Moved to another category, both are recounted
+	product.CategoryID = &erkek
+	if err := s.ProcessProducts(ctx, []models.Product{product}); err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := ds.GetCategoryTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	var walk func([]models.Category)
+	walk = func(categories []models.Category) {
+		for _, c := range categories {
+			counts[c.ID] = c.ProductCount
+			walk(c.Children)
+		}
+	}
+	walk(tree)
+	if want := map[string]int{kadin: 0, elbise: 0, erkek: 1}; fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Errorf("product counts = %v, want %v", counts, want)
+	}
+}
+
+func mustDatabaseStorage(t *testing.T, db *gorm.DB) *storage.DatabaseStorage {
+	ds, err := storage.NewDatabaseStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
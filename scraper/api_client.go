@@ -0,0 +1,266 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"trendyol-scraper/config"
+	"trendyol-scraper/frontier"
+	"trendyol-scraper/models"
+)
+
+// ListingPage is one page of the listing/search JSON endpoint.
+type ListingPage struct {
+	Page       int
+	Products   []models.Product
+	TotalCount int
+}
+
+// APIClient pages through the storefront's listing/search JSON endpoint,
+// which returns the same data.contents payload as data.json, so products
+// need no HTML parsing at all.
+type APIClient struct {
+	config     *config.Config
+	httpClient *http.Client
+	limiter    *RateLimiter
+	retry      *RetryPolicy
+	identities *Identities
+	proxies    *ProxyPool
+	frontier   frontier.Frontier
+}
+
+func NewAPIClient(cfg *config.Config, limiter *RateLimiter, retry *RetryPolicy, proxies *ProxyPool, f frontier.Frontier) *APIClient {
+	timeout := time.Duration(cfg.Scraper.API.TimeoutSeconds) * time.Second
+
+	// Each request picks its proxy, see get
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFromContext
+
+	return &APIClient{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: timeout, Transport: transport},
+		limiter:    limiter,
+		retry:      retry,
+		identities: NewIdentities(cfg),
+		proxies:    proxies,
+		frontier:   f,
+	}
+}
+
+// CategoryProducts returns every product listed under categoryURL.
+func (c *APIClient) CategoryProducts(ctx context.Context, categoryURL string) ([]models.Product, error) {
+	u, err := url.Parse(categoryURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid category URL %q: %w", categoryURL, err)
+	}
+
+	params := u.Query()
+	params.Set("pathModel", u.Path)
+	return c.collect(ctx, categoryURL, params)
+}
+
+// SearchProducts returns every product matching a free-text query.
+func (c *APIClient) SearchProducts(ctx context.Context, query string) ([]models.Product, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	searchURL := strings.TrimRight(c.config.Scraper.BaseURL, "/") + "/sr?" + params.Encode()
+	return c.collect(ctx, searchURL, params)
+}
+
+// collect fetches the first page to learn the total count, then queues the
+// remaining pages in the frontier and fetches them concurrently. Without a
+// total count it pages sequentially until a page comes back empty or only
+// repeats products of earlier pages. Either way it stops at
+// scraper.max_pages, and a product listed on several pages is returned
+// once. Pages are keyed by their storefront URL, so an interrupted crawl of
+// sourceURL resumes with the pages it has not finished. On error the
+// products of the pages that did load are returned with it.
+func (c *APIClient) collect(ctx context.Context, sourceURL string, params url.Values) ([]models.Product, error) {
+	scope := "api " + sourceURL
+	done, err := resume(ctx, c.frontier, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	fetch := func(page int) (*ListingPage, error) {
+		pageURL := withPage(sourceURL, page)
+		return visit(ctx, c.frontier, scope, pageURL, done, func() (*ListingPage, error) {
+			return withRetry(ctx, c.retry, pageURL, func() (*ListingPage, error) {
+				return c.FetchPage(ctx, params, page)
+			})
+		})
+	}
+
+	first, err := fetch(1)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Fetched page 1: %d products (%d total)", len(first.Products), first.TotalCount)
+
+	maxPages := c.config.Scraper.MaxPages
+	if first.TotalCount == 0 && len(first.Products) > 0 {
+		seen := newProductSet()
+		seen.dedupe(first.Products)
+		for page := 2; page <= maxPages; page++ {
+			listing, err := fetch(page)
+			if err != nil {
+				break // the pages so far are returned with the error below
+			}
+			if len(listing.Products) == 0 {
+				break // No more products
+			}
+			if len(seen.dedupe(listing.Products)) == 0 {
+				log.Printf("Page %d of %s repeats earlier pages, stopping", page, sourceURL)
+				break
+			}
+			log.Printf("Fetched page %d: %d products", page, len(listing.Products))
+		}
+	} else if len(first.Products) > 0 {
+		pageSize := len(first.Products)
+		lastPage := (first.TotalCount + pageSize - 1) / pageSize
+		if lastPage > maxPages {
+			log.Printf("%s has %d pages, fetching the first %d (scraper.max_pages)", sourceURL, lastPage, maxPages)
+			lastPage = maxPages
+		}
+		var urls []string
+		for page := 2; page <= lastPage; page++ {
+			urls = append(urls, withPage(sourceURL, page))
+		}
+
+		if err := c.frontier.Add(ctx, scope, 0, urls...); err != nil {
+			return nil, err
+		}
+		err := drain(ctx, c.frontier, scope, c.config.Scraper.Workers, func(ctx context.Context, _ int, e frontier.Entry) (*ListingPage, error) {
+			return withRetry(ctx, c.retry, e.URL, func() (*ListingPage, error) {
+				return c.FetchPage(ctx, params, pageOf(e.URL))
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	listings, err := results(ctx, c.frontier, scope, done, func(listing *ListingPage) {
+		for i := range listing.Products {
+			listing.Products[i].Replayed = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	seen := newProductSet()
+	var products []models.Product
+	for _, listing := range listings {
+		products = append(products, seen.dedupe(listing.Products)...)
+	}
+	log.Printf("Fetched %d pages: %d/%d products", len(listings), len(products), first.TotalCount)
+
+	return products, finish(ctx, c.frontier, scope)
+}
+
+// FetchPage requests a single listing page. params select the category or
+// query; the configured api.params are added to every request.
+func (c *APIClient) FetchPage(ctx context.Context, params url.Values, page int) (*ListingPage, error) {
+	endpoint, err := url.Parse(c.config.Scraper.API.SearchURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scraper.api.search_url: %w", err)
+	}
+
+	q := endpoint.Query()
+	for k, v := range c.config.Scraper.API.Params {
+		q.Set(k, v)
+	}
+	for k, vs := range params {
+		for _, v := range vs {
+			q.Add(k, v)
+		}
+	}
+	q.Set("pi", strconv.Itoa(page))
+	endpoint.RawQuery = q.Encode()
+
+	body, err := c.get(ctx, endpoint.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch listing page %d: %w", page, err)
+	}
+
+	listing, err := decodeListing(body)
+	if err != nil {
+		// A challenge page comes back as HTML instead of JSON
+		if trimmed := bytes.TrimSpace(body); bytes.HasPrefix(trimmed, []byte("<")) || looksBlocked(string(body)) {
+			return nil, fmt.Errorf("%w: listing page %d is not JSON", ErrBlocked, page)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrLayoutChanged, err)
+	}
+	if !listing.IsSuccess && listing.StatusCode != 0 && listing.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing page %d returned status %d", page, listing.StatusCode)
+	}
+
+	products := listing.Data.Contents
+	for i := range products {
+		normalizeProduct(&products[i], c.config.Scraper.BaseURL)
+	}
+
+	return &ListingPage{
+		Page:       page,
+		Products:   products,
+		TotalCount: listing.Data.TotalCount,
+	}, nil
+}
+
+// get fetches rawURL once through the rate limiter, the next proxy and the
+// next identity. A 429 or 503 comes back as a throttledError; retrying
+// and pausing the host are left to withRetry. A proxy that is throttled
+// or blocked is rested, and one that cannot be reached is left out.
+func (c *APIClient) get(ctx context.Context, rawURL string) ([]byte, error) {
+	if err := c.limiter.Wait(ctx, rawURL); err != nil {
+		return nil, err
+	}
+	proxy, err := c.proxies.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(withProxy(ctx, proxy), http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	apply(c.identities.Next(), req)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if isProxyError(err) {
+			c.proxies.Fail(proxy)
+		}
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		c.limiter.Success(rawURL)
+		return body, nil
+	case IsThrottled(resp.StatusCode):
+		c.proxies.Cooldown(proxy)
+		return nil, &throttledError{status: resp.StatusCode, retryAfter: resp.Header.Get("Retry-After")}
+	}
+	statusErr := statusError(resp.StatusCode)
+	if statusErr == ErrBlocked {
+		c.proxies.Cooldown(proxy)
+	}
+	if statusErr != nil {
+		return nil, fmt.Errorf("%w: HTTP %d", statusErr, resp.StatusCode)
+	}
+	return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
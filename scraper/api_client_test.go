@@ -0,0 +1,213 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"trendyol-scraper/config"
+	"trendyol-scraper/frontier"
+	"trendyol-scraper/models"
+)
+
+// listingServer serves pages[pi-1] of the listing endpoint, each a list of
+// product IDs, and records which pages were asked for.
+type listingServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	requested []int
+}
+
+func newListingServer(t *testing.T, totalCount int, pages [][]int) *listingServer {
+	s := &listingServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("pi"))
+		s.mu.Lock()
+		s.requested = append(s.requested, page)
+		s.mu.Unlock()
+
+		type product struct {
+			ID  int    `json:"id"`
+			URL string `json:"url"`
+		}
+		var resp struct {
+			Data struct {
+				Contents   []product `json:"contents"`
+				TotalCount int       `json:"totalCount"`
+			} `json:"data"`
+			IsSuccess bool `json:"isSuccess"`
+		}
+		resp.IsSuccess = true
+		resp.Data.TotalCount = totalCount
+		resp.Data.Contents = []product{}
+		if page >= 1 && page <= len(pages) {
+			for _, id := range pages[page-1] {
+				resp.Data.Contents = append(resp.Data.Contents, product{ID: id, URL: "/brand/item-p-" + strconv.Itoa(id)})
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *listingServer) pages() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pages := append([]int(nil), s.requested...)
+	// Pages past the first are fetched concurrently when the total is known
+	sort.Ints(pages)
+	return pages
+}
+
+func newTestAPIClient(t *testing.T, searchURL string, maxPages int) *APIClient {
+	cfg := &config.Config{}
+	cfg.Scraper.BaseURL = "https://www.trendyol.com"
+	cfg.Scraper.MaxPages = maxPages
+	cfg.Scraper.Workers = 2
+	cfg.Scraper.API.SearchURL = searchURL
+	cfg.Scraper.API.TimeoutSeconds = 5
+	limiter := NewRateLimiter(cfg)
+	proxies, err := NewProxyPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAPIClient(cfg, limiter, newTestRetryPolicy(limiter), proxies, frontier.NewMemory(1))
+}
+
+func TestCollectStops(t *testing.T) {
+	tests := []struct {
+		name         string
+		totalCount   int
+		pages        [][]int
+		maxPages     int
+		wantProducts []int
+		wantPages    []int
+	}{
+		{
+			name:         "last page by total count",
+			totalCount:   5,
+			pages:        [][]int{{1, 2}, {3, 4}, {5}, {6, 7}},
+			maxPages:     100,
+			wantProducts: []int{1, 2, 3, 4, 5},
+			wantPages:    []int{1, 2, 3},
+		},
+		{
+			name:         "total count capped by max pages",
+			totalCount:   6,
+			pages:        [][]int{{1, 2}, {3, 4}, {5, 6}},
+			maxPages:     2,
+			wantProducts: []int{1, 2, 3, 4},
+			wantPages:    []int{1, 2},
+		},
+		{
+			name:         "products repeated across pages are returned once",
+			totalCount:   6,
+			pages:        [][]int{{1, 2}, {2, 3}, {1, 4}},
+			maxPages:     100,
+			wantProducts: []int{1, 2, 3, 4},
+			wantPages:    []int{1, 2, 3},
+		},
+		{
+			name:         "empty page without a total",
+			pages:        [][]int{{1, 2}, {3, 4}},
+			maxPages:     100,
+			wantProducts: []int{1, 2, 3, 4},
+			wantPages:    []int{1, 2, 3},
+		},
+		{
+			name:         "repeated last page without a total",
+			pages:        [][]int{{1, 2}, {3, 4}, {3, 4}, {5, 6}},
+			maxPages:     100,
+			wantProducts: []int{1, 2, 3, 4},
+			wantPages:    []int{1, 2, 3},
+		},
+		{
+			name:         "max pages without a total",
+			pages:        [][]int{{1, 2}, {3, 4}, {5, 6}, {7, 8}},
+			maxPages:     3,
+			wantProducts: []int{1, 2, 3, 4, 5, 6},
+			wantPages:    []int{1, 2, 3},
+		},
+		{
+			name:      "empty first page",
+			pages:     [][]int{{}},
+			maxPages:  100,
+			wantPages: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newListingServer(t, tt.totalCount, tt.pages)
+			client := newTestAPIClient(t, srv.URL+"/search", tt.maxPages)
+
+			products, err := client.SearchProducts(context.Background(), "shoes")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, p := range products {
+				ids = append(ids, p.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantProducts) {
+				t.Errorf("products = %v, want %v", ids, tt.wantProducts)
+			}
+			if got := srv.pages(); !reflect.DeepEqual(got, tt.wantPages) {
+				t.Errorf("requested pages = %v, want %v", got, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestGetThrottled(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+	client := newTestAPIClient(t, srv.URL+"/search", 1)
+
+	_, err := client.get(context.Background(), srv.URL+"/search?pi=1")
+	var throttled *throttledError
+	if !errors.As(err, &throttled) || throttled.retryAfter != "30" || !errors.Is(err, ErrBlocked) {
+		t.Errorf("err = %v, want a blocked error keeping Retry-After", err)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests, want one; retries are up to withRetry", requests)
+	}
+}
+
+func TestCollectMarksReplayedProducts(t *testing.T) {
+	ctx := context.Background()
+	srv := newListingServer(t, 4, [][]int{{1, 2}, {3, 4}})
+	client := newTestAPIClient(t, srv.URL+"/search", 100)
+
+	// An earlier run finished page 1 and was interrupted
+	sourceURL := "https://www.trendyol.com/sr?q=shoes"
+	scope, first := "api "+sourceURL, withPage(sourceURL, 1)
+	client.frontier.Add(ctx, scope, 0, first)
+	client.frontier.Done(ctx, scope, first, ListingPage{Page: 1, TotalCount: 4, Products: []models.Product{{ID: 1}, {ID: 2}}})
+
+	products, err := client.SearchProducts(ctx, "shoes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := map[int]bool{}
+	for _, p := range products {
+		replayed[p.ID] = p.Replayed
+	}
+	if want := map[int]bool{1: true, 2: true, 3: false, 4: false}; !reflect.DeepEqual(replayed, want) {
+		t.Errorf("replayed = %v, want %v", replayed, want)
+	}
+	if got := srv.pages(); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("requested pages = %v, want only page 2", got)
+	}
+}
@@ -0,0 +1,242 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// loadPage navigates the tab in ctx to url, waits for selector (unless it
+// is empty) and then runs actions, all within timeout. Failures are classified: error
+// statuses, challenge pages, slow pages and a selector that never shows up
+// on a page that did load.
+func loadPage(ctx context.Context, url, selector string, timeout time.Duration, actions ...chromedp.Action) error {
+	navCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := chromedp.RunResponse(navCtx, chromedp.Navigate(url))
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case err != nil && isTimeout(err):
+		return fmt.Errorf("%w: loading %s", ErrTimeout, url)
+	case err != nil:
+		return fmt.Errorf("failed to load %s: %w", url, err)
+	case resp != nil && resp.Status >= 400:
+		if statusErr := statusError(int(resp.Status)); statusErr != nil {
+			return fmt.Errorf("%w: %s answered HTTP %d", statusErr, url, resp.Status)
+		}
+		return fmt.Errorf("%s answered HTTP %d", url, resp.Status)
+	}
+
+	if selector != "" {
+		actions = append([]chromedp.Action{chromedp.WaitVisible(selector)}, actions...)
+	}
+	err = chromedp.Run(navCtx, actions...)
+	if err == nil || ctx.Err() != nil || !isTimeout(err) {
+		return err
+	}
+
+	// Look at what did load to tell a challenge page from a changed layout
+	checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var html, readyState string
+	if err := chromedp.Run(checkCtx,
+		chromedp.Evaluate(`document.readyState`, &readyState),
+		chromedp.OuterHTML("html", &html),
+	); err != nil || readyState != "complete" {
+		return fmt.Errorf("%w: waiting for %s", ErrTimeout, url)
+	}
+	if looksBlocked(html) {
+		return fmt.Errorf("%w: challenge page at %s", ErrBlocked, url)
+	}
+	return fmt.Errorf("%w: %s not found on %s", ErrLayoutChanged, selector, url)
+}
+
+// browserSession is a tab with its own identity, and its own proxy when the
+// pool has any.
+type browserSession struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	proxy  *Proxy
+}
+
+// browsers opens headless Chrome sessions. Chrome takes its proxy per
+// process, so with proxies every session is a browser of its own; without
+// them sessions are tabs of one shared browser.
+type browsers struct {
+	ctx        context.Context
+	identities *Identities
+	proxies    *ProxyPool
+
+	mu           sync.Mutex
+	shared       context.Context
+	sharedCancel context.CancelFunc
+	open         map[*browserSession]struct{}
+}
+
+func newBrowsers(ctx context.Context, identities *Identities, proxies *ProxyPool) *browsers {
+	return &browsers{ctx: ctx, identities: identities, proxies: proxies, open: map[*browserSession]struct{}{}}
+}
+
+func allocatorOptions(userAgent string) []chromedp.ExecAllocatorOption {
+	return append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("no-sandbox", true),
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.UserAgent(userAgent),
+	)
+}
+
+// session starts a tab with the next identity and proxy.
+func (b *browsers) session() (*browserSession, error) {
+	identity := b.identities.Next()
+	proxy, err := b.proxies.Get(b.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &browserSession{proxy: proxy}
+	if proxy == nil {
+		b.mu.Lock()
+		if b.shared == nil {
+			allocCtx, cancelAlloc := chromedp.NewExecAllocator(b.ctx, allocatorOptions(identity.UserAgent)...)
+			browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+			b.shared = browserCtx
+			b.sharedCancel = func() { cancelBrowser(); cancelAlloc() }
+		}
+		b.mu.Unlock()
+		s.ctx, s.cancel = chromedp.NewContext(b.shared)
+	} else {
+		if proxy.URL.User != nil && proxy.URL.Scheme == "socks5" {
+			return nil, fmt.Errorf("proxy %s: Chrome cannot authenticate to SOCKS5 proxies", proxy)
+		}
+		// Chrome takes the proxy without credentials; they are given
+		// when the proxy asks, see proxyAuth
+		opts := append(allocatorOptions(identity.UserAgent), chromedp.ProxyServer(proxy.URL.Scheme+"://"+proxy.URL.Host))
+		allocCtx, cancelAlloc := chromedp.NewExecAllocator(b.ctx, opts...)
+		tabCtx, cancelTab := chromedp.NewContext(allocCtx)
+		s.ctx, s.cancel = tabCtx, func() { cancelTab(); cancelAlloc() }
+	}
+
+	headers := network.Headers{}
+	for k, v := range identity.Headers {
+		headers[k] = v
+	}
+	actions := []chromedp.Action{
+		emulation.SetUserAgentOverride(identity.UserAgent),
+		network.Enable(),
+		network.SetExtraHTTPHeaders(headers),
+	}
+	if proxy != nil && proxy.URL.User != nil {
+		actions = append(actions, proxyAuth(s.ctx, proxy.URL.User))
+	}
+	if err := chromedp.Run(s.ctx, actions...); err != nil {
+		s.cancel()
+		if isProxyError(err) {
+			b.proxies.Fail(proxy)
+		}
+		return nil, fmt.Errorf("failed to start browser: %w", err)
+	}
+
+	b.mu.Lock()
+	b.open[s] = struct{}{}
+	b.mu.Unlock()
+	return s, nil
+}
+
+// proxyAuth answers the proxy's authentication challenges in the tab with
+// user. It intercepts every request of the tab, so each is continued
+// unchanged.
+func proxyAuth(tabCtx context.Context, user *url.Userinfo) chromedp.Action {
+	password, _ := user.Password()
+	chromedp.ListenTarget(tabCtx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *fetch.EventAuthRequired:
+			response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseDefault}
+			if ev.AuthChallenge != nil && ev.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+				response = &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+					Username: user.Username(),
+					Password: password,
+				}
+			}
+			go runInTab(tabCtx, fetch.ContinueWithAuth(ev.RequestID, response))
+		case *fetch.EventRequestPaused:
+			go runInTab(tabCtx, fetch.ContinueRequest(ev.RequestID))
+		}
+	})
+	return fetch.Enable().WithHandleAuthRequests(true)
+}
+
+// runInTab runs action from an event listener, which may not block.
+func runInTab(tabCtx context.Context, action chromedp.Action) {
+	c := chromedp.FromContext(tabCtx)
+	if c == nil || c.Target == nil {
+		return
+	}
+	_ = action.Do(cdp.WithExecutor(tabCtx, c.Target))
+}
+
+// use runs fn in the session held in slot, starting one first if needed.
+// A session that gets blocked or loses its proxy is closed, and its proxy
+// rested or left out, so the next call starts over with a fresh identity
+// and proxy.
+func (b *browsers) use(slot **browserSession, fn func(tabCtx context.Context) error) error {
+	if *slot == nil {
+		s, err := b.session()
+		if err != nil {
+			return err
+		}
+		*slot = s
+	}
+
+	s := *slot
+	err := fn(s.ctx)
+	switch {
+	case err == nil:
+		return nil
+	case Classify(err) == ClassBlocked:
+		b.proxies.Cooldown(s.proxy)
+	case isProxyError(err):
+		b.proxies.Fail(s.proxy)
+	default:
+		return err
+	}
+
+	b.release(s)
+	*slot = nil
+	return err
+}
+
+func (b *browsers) release(s *browserSession) {
+	s.cancel()
+	b.mu.Lock()
+	delete(b.open, s)
+	b.mu.Unlock()
+}
+
+// close shuts down every session and the shared browser.
+func (b *browsers) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.open {
+		s.cancel()
+	}
+	b.open = map[*browserSession]struct{}{}
+	if b.sharedCancel != nil {
+		b.sharedCancel()
+		b.shared, b.sharedCancel = nil, nil
+	}
+}
@@ -2,122 +2,179 @@ package scraper
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/chromedp/chromedp"
-	"trendyol-scraper/models"
 	"trendyol-scraper/config"
+	"trendyol-scraper/frontier"
+	"trendyol-scraper/models"
 )
 
 type CategoryScraper struct {
-	config *config.Config
+	config     *config.Config
+	profiles   *Profiles
+	limiter    *RateLimiter
+	retry      *RetryPolicy
+	identities *Identities
+	proxies    *ProxyPool
+	frontier   frontier.Frontier
 }
 
-func NewCategoryScraper(cfg *config.Config) *CategoryScraper {
-	return &CategoryScraper{config: cfg}
+func NewCategoryScraper(cfg *config.Config, limiter *RateLimiter, retry *RetryPolicy, proxies *ProxyPool, f frontier.Frontier) *CategoryScraper {
+	return &CategoryScraper{
+		config:     cfg,
+		profiles:   NewProfiles(cfg),
+		limiter:    limiter,
+		retry:      retry,
+		identities: NewIdentities(cfg),
+		proxies:    proxies,
+		frontier:   f,
+	}
 }
 
-func (cs *CategoryScraper) ScrapeCategories() ([]models.Category, error) {
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("no-sandbox", true),
-		chromedp.Flag("disable-dev-shm-usage", true),
-		chromedp.UserAgent(cs.config.Scraper.UserAgent),
-	)
-
-	ctx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
+// ScrapeCategories crawls the category tree breadth first from the home
+// page. Every page is a frontier entry holding the categories found on it,
+// so an interrupted crawl resumes with the pages it has not visited and
+// the tree is assembled from the checkpoint at the end.
+func (cs *CategoryScraper) ScrapeCategories(ctx context.Context) ([]models.Category, error) {
+	browsers := newBrowsers(ctx, cs.identities, cs.proxies)
+	defer browsers.close()
 
-	ctx, cancel = chromedp.NewContext(ctx, chromedp.WithLogf(log.Printf))
-	defer cancel()
-
-	// Add timeout to context
-	ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	var categories []models.Category
 	baseURL := cs.config.Scraper.BaseURL
+	scope := "categories " + baseURL
+	if _, err := resume(ctx, cs.frontier, scope); err != nil {
+		return nil, err
+	}
+	if err := cs.frontier.Add(ctx, scope, 0, baseURL); err != nil {
+		return nil, err
+	}
 
-	// Navigate to main page and extract top-level categories
-	log.Printf("Navigating to %s", baseURL)
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(baseURL),
-		chromedp.WaitVisible(`nav`),
-		chromedp.Sleep(2*time.Second),
-		chromedp.Evaluate(`
-			Array.from(document.querySelectorAll('nav a')).map(el => ({
-				name: el.innerText.trim(),
-				url: el.href
-			})).filter(cat => cat.name && cat.url)
-		`, &categories),
-	)
+	// One session, so pages are visited one at a time
+	var session *browserSession
+	err := drain(ctx, cs.frontier, scope, 1, func(ctx context.Context, _ int, e frontier.Entry) ([]models.Category, error) {
+		categories, err := withRetry(ctx, cs.retry, e.URL, func() ([]models.Category, error) {
+			if err := cs.limiter.Wait(ctx, e.URL); err != nil {
+				return nil, err
+			}
+			var categories []models.Category
+			err := browsers.use(&session, func(tabCtx context.Context) error {
+				var err error
+				categories, err = cs.scrapePage(tabCtx, e)
+				return err
+			})
+			return categories, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if e.Depth < cs.config.Scraper.MaxDepth {
+			urls := make([]string, len(categories))
+			for i, c := range categories {
+				urls[i] = c.URL
+			}
+			if err := cs.frontier.Add(ctx, scope, e.Depth+1, urls...); err != nil {
+				return nil, err
+			}
+		}
+		return categories, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	entries, err := cs.frontier.Entries(ctx, scope)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scrape top-level categories: %w", err)
+		return nil, err
+	}
+	pages := make(map[string][]models.Category, len(entries))
+	for _, e := range entries {
+		if e.Status != frontier.Done {
+			log.Printf("Warning: failed to scrape categories from %s: %s", e.URL, e.LastError)
+			continue
+		}
+		var categories []models.Category
+		if err := json.Unmarshal(e.Data, &categories); err != nil {
+			return nil, fmt.Errorf("failed to decode checkpoint of %s: %w", e.URL, err)
+		}
+		pages[e.URL] = categories
 	}
 
+	categories, ok := pages[baseURL]
+	if !ok {
+		return nil, finish(ctx, cs.frontier, scope)
+	}
 	log.Printf("Found %d top-level categories", len(categories))
 
-	// Scrape subcategories for each top-level category
 	for i := range categories {
-		if err := cs.scrapeSubcategories(ctx, &categories[i], 1); err != nil {
-			log.Printf("Warning: failed to scrape subcategories for %s: %v", categories[i].Name, err)
-			continue
-		}
-		time.Sleep(time.Duration(cs.config.Scraper.DelaySeconds) * time.Second)
+		cs.buildTree(&categories[i], pages, 1)
 	}
 
-	return categories, nil
+	return categories, finish(ctx, cs.frontier, scope)
 }
 
-func (cs *CategoryScraper) scrapeSubcategories(ctx context.Context, parent *models.Category, depth int) error {
+// scrapePage returns the categories linked from one page: the top-level
+// navigation on the home page and the subcategories anywhere else.
+func (cs *CategoryScraper) scrapePage(tabCtx context.Context, e frontier.Entry) ([]models.Category, error) {
+	// The timeout covers the page only; rate limit waits are not counted
+	timeout := time.Duration(cs.config.Scraper.PageTimeoutSeconds) * time.Second
+	selectors := cs.profiles.For(e.URL).Selectors
+	log.Printf("Scraping categories from %s at depth %d", e.URL, e.Depth)
+
+	var html string
+	var err error
+	if e.Depth == 0 {
+		err = loadPage(tabCtx, e.URL, selectors.Navigation, timeout,
+			chromedp.Sleep(2*time.Second),
+			chromedp.OuterHTML("html", &html),
+		)
+	} else {
+		err = loadPage(tabCtx, e.URL, selectors.Subcategories, timeout,
+			chromedp.OuterHTML("html", &html),
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape categories: %w", err)
+	}
+
+	if e.Depth == 0 {
+		return ParseTopCategories([]byte(html), e.URL, cs.profiles)
+	}
+	return ParseSubcategories([]byte(html), e.URL, cs.profiles)
+}
+
+// buildTree attaches the subcategories scraped from parent's page. Pages
+// that could not be scraped leave parent without children.
+func (cs *CategoryScraper) buildTree(parent *models.Category, pages map[string][]models.Category, depth int) {
 	if depth > cs.config.Scraper.MaxDepth {
 		parent.IsLeaf = true
-		return nil
-	}
-
-	log.Printf("Scraping subcategories for %s at depth %d", parent.Name, depth)
-	time.Sleep(time.Duration(cs.config.Scraper.DelaySeconds) * time.Second)
-
-	// Create a new context with timeout for this subcategory
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	var subcategories []models.Category
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(parent.URL),
-		chromedp.WaitVisible(`.sub-category-header`),
-		chromedp.Evaluate(`
-			Array.from(document.querySelectorAll('.sub-category-header')).map(el => ({
-				name: el.innerText.trim(),
-				url: el.href
-			}))
-		`, &subcategories),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to scrape subcategories: %w", err)
+		return
 	}
 
+	subcategories, ok := pages[parent.URL]
+	if !ok {
+		return
+	}
 	if len(subcategories) == 0 {
 		parent.IsLeaf = true
-		return nil
+		return
 	}
 
-	// Set parent reference
+	// Copy so a category listed under several parents gets its own children
+	subcategories = append([]models.Category(nil), subcategories...)
+
+	// Set parent reference; a copy, as parent may still move
+	parentID := parent.ID
 	for i := range subcategories {
-		subcategories[i].ParentID = &parent.ID
+		subcategories[i].ParentID = &parentID
 	}
 
-	// Recursively scrape deeper levels
+	// Recursively attach deeper levels
 	for i := range subcategories {
-		if err := cs.scrapeSubcategories(ctx, &subcategories[i], depth+1); err != nil {
-			return err
-		}
+		cs.buildTree(&subcategories[i], pages, depth+1)
 	}
 
 	parent.Children = subcategories
-	return nil
-}
\ No newline at end of file
+}
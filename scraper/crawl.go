@@ -0,0 +1,162 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"trendyol-scraper/frontier"
+)
+
+// resume queues again whatever an earlier run of scope left in progress or
+// failed, and returns the entries it finished, keyed by URL.
+func resume(ctx context.Context, f frontier.Frontier, scope string) (map[string]frontier.Entry, error) {
+	requeued, err := f.Requeue(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := f.Entries(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]frontier.Entry)
+	for _, e := range entries {
+		if e.Status == frontier.Done {
+			done[e.URL] = e
+		}
+	}
+	if len(done) > 0 || requeued > 0 {
+		log.Printf("Resuming %s: %d URLs done, %d queued again", scope, len(done), requeued)
+	}
+	return done, nil
+}
+
+// visit returns the result an earlier run recorded for url, or calls fetch
+// and records its outcome.
+func visit[T any](ctx context.Context, f frontier.Frontier, scope, url string, done map[string]frontier.Entry, fetch func() (T, error)) (T, error) {
+	var result T
+	if e, ok := done[url]; ok {
+		if err := json.Unmarshal(e.Data, &result); err == nil {
+			return result, nil
+		}
+	}
+
+	result, err := fetch()
+	if err != nil {
+		if ctx.Err() == nil {
+			if ferr := f.Fail(ctx, scope, url, err); ferr != nil {
+				log.Printf("Failed to record failure of %s: %v", url, ferr)
+			}
+		}
+		return result, err
+	}
+	return result, f.Done(ctx, scope, url, result)
+}
+
+// drain claims the queued entries of scope and runs fn on them with the
+// worker pool, recording each outcome, until nothing is left queued. fn
+// does its own retrying (see withRetry); the frontier queues an entry that
+// failed anyway again only on the next run.
+func drain[T any](ctx context.Context, f frontier.Frontier, scope string, workers int, fn func(ctx context.Context, worker int, e frontier.Entry) (T, error)) error {
+	for {
+		entries, err := f.Next(ctx, scope, workers*4)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		results := runPool(ctx, workers, entries, fn)
+		for i, result := range results {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			url := entries[i].URL
+			if result.Err != nil {
+				log.Printf("Failed to scrape %s: %v", url, result.Err)
+				err = f.Fail(ctx, scope, url, result.Err)
+			} else {
+				err = f.Done(ctx, scope, url, result.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// results decodes the data of every finished entry of scope in order.
+// Entries in done were finished by an earlier run; replayed marks their
+// results so they are not taken for fresh ones.
+func results[T any](ctx context.Context, f frontier.Frontier, scope string, done map[string]frontier.Entry, replayed func(*T)) ([]T, error) {
+	entries, err := f.Entries(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []T
+	for _, e := range entries {
+		if e.Status != frontier.Done {
+			continue
+		}
+		var v T
+		if err := json.Unmarshal(e.Data, &v); err != nil {
+			return nil, fmt.Errorf("failed to decode checkpoint of %s: %w", e.URL, err)
+		}
+		if _, ok := done[e.URL]; ok {
+			replayed(&v)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// finish forgets scope once every URL is done, so the next crawl starts
+// fresh. Otherwise the checkpoint is kept and an error says what is left;
+// running the crawl again retries those URLs.
+func finish(ctx context.Context, f frontier.Frontier, scope string) error {
+	entries, err := f.Entries(ctx, scope)
+	if err != nil {
+		return err
+	}
+
+	left := 0
+	for _, e := range entries {
+		if e.Status != frontier.Done {
+			left++
+		}
+	}
+	if left > 0 {
+		return fmt.Errorf("%s: %d of %d URLs failed; run again to retry them", scope, left, len(entries))
+	}
+	return f.Clear(ctx, scope)
+}
+
+// withPage sets the listing page number of a storefront URL.
+func withPage(rawURL string, page int) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Sprintf("%s?pi=%d", rawURL, page)
+	}
+	q := u.Query()
+	q.Set("pi", strconv.Itoa(page))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// pageOf returns the listing page number of a URL built by withPage.
+func pageOf(rawURL string) int {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 1
+	}
+	page, err := strconv.Atoi(u.Query().Get("pi"))
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
+}
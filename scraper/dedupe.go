@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"net/url"
+	"strconv"
+	"sync"
+	"trendyol-scraper/models"
+)
+
+// productKey identifies a product across listing pages and categories by
+// the number in its -p-<id> URL, or by the URL without query when it has
+// none. Listings add tracking parameters such as boutiqueId to the links.
+func productKey(rawURL string) string {
+	if m := productIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+		return m[1]
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		u.RawQuery, u.Fragment = "", ""
+		return u.String()
+	}
+	return rawURL
+}
+
+func keyOf(p models.Product) string {
+	if p.ID != 0 {
+		return strconv.Itoa(p.ID)
+	}
+	return productKey(p.URL)
+}
+
+// productSet remembers which products were seen. It is safe for concurrent
+// use.
+type productSet struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func newProductSet() *productSet {
+	return &productSet{keys: make(map[string]struct{})}
+}
+
+// add records key and reports whether it is new.
+func (s *productSet) add(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+	s.keys[key] = struct{}{}
+	return true
+}
+
+func (s *productSet) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.keys[key]
+	return ok
+}
+
+// dedupe returns the products not seen before, keeping the first of any
+// repeats, and records them.
+func (s *productSet) dedupe(products []models.Product) []models.Product {
+	var out []models.Product
+	for _, p := range products {
+		if s.add(keyOf(p)) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
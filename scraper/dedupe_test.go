@@ -0,0 +1,80 @@
+package scraper
+
+import (
+	"sync"
+	"testing"
+	"trendyol-scraper/models"
+)
+
+func TestProductKey(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://www.trendyol.com/apple/iphone-15-p-123456", "123456"},
+		{"https://www.trendyol.com/apple/iphone-15-p-123456?boutiqueId=61&merchantId=968", "123456"},
+		{"/apple/iphone-15-p-123456", "123456"},
+		{"https://www.trendyol.com/apple/iphone-15?boutiqueId=61#reviews", "https://www.trendyol.com/apple/iphone-15"},
+	}
+	for _, tt := range tests {
+		if got := productKey(tt.url); got != tt.want {
+			t.Errorf("productKey(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestProductSetDedupe(t *testing.T) {
+	s := newProductSet()
+	first := s.dedupe([]models.Product{
+		{ID: 1, Name: "first"},
+		{ID: 2},
+		{ID: 1, Name: "repeat on the same page"},
+		{URL: "https://www.trendyol.com/x/y-p-3?boutiqueId=1"},
+		{URL: "https://www.trendyol.com/x/no-id?a=1"},
+	})
+	if len(first) != 4 || first[0].Name != "first" {
+		t.Errorf("first page = %+v, want 4 products keeping the first of a repeat", first)
+	}
+
+	// Keys are shared across pages and categories, and an ID matches the
+	// -p-<id> of a URL
+	second := s.dedupe([]models.Product{
+		{ID: 3},
+		{URL: "/x/y-p-2"},
+		{URL: "https://www.trendyol.com/x/no-id?b=2"},
+		{ID: 4},
+	})
+	if len(second) != 1 || second[0].ID != 4 {
+		t.Errorf("second page = %+v, want only product 4", second)
+	}
+
+	if !s.has("4") || s.has("5") {
+		t.Error("has does not reflect the products seen")
+	}
+	if s.dedupe(nil) != nil {
+		t.Error("dedupe(nil) != nil")
+	}
+}
+
+func TestProductSetConcurrent(t *testing.T) {
+	s := newProductSet()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	added := 0
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := 1; id <= 100; id++ {
+				if n := len(s.dedupe([]models.Product{{ID: id}})); n > 0 {
+					mu.Lock()
+					added += n
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if added != 100 {
+		t.Errorf("%d products kept by 8 workers, want each of the 100 once", added)
+	}
+}
@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Scraping errors are wrapped around one of these so the retry policy can
+// tell failures that may pass from ones that will not.
+var (
+	ErrNotFound      = errors.New("page not found")
+	ErrBlocked       = errors.New("blocked by the site")
+	ErrLayoutChanged = errors.New("page layout changed")
+	ErrTimeout       = errors.New("timed out")
+)
+
+// ErrorClass groups errors for retries and the run summary.
+type ErrorClass string
+
+const (
+	ClassNotFound      ErrorClass = "not_found"
+	ClassBlocked       ErrorClass = "blocked"
+	ClassLayoutChanged ErrorClass = "layout_changed"
+	ClassTimeout       ErrorClass = "timeout"
+	ClassOther         ErrorClass = "other"
+)
+
+var errorClasses = []ErrorClass{ClassNotFound, ClassBlocked, ClassLayoutChanged, ClassTimeout, ClassOther}
+
+// Classify returns the class of err. Timeouts from the network or a
+// context deadline count as ErrTimeout even when not wrapped.
+func Classify(err error) ErrorClass {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return ClassNotFound
+	case errors.Is(err, ErrBlocked), errors.Is(err, ErrDisallowed):
+		return ClassBlocked
+	case errors.Is(err, ErrLayoutChanged):
+		return ClassLayoutChanged
+	case errors.Is(err, ErrTimeout), isTimeout(err):
+		return ClassTimeout
+	default:
+		return ClassOther
+	}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// statusError maps an unexpected HTTP status to its error class.
+func statusError(status int) error {
+	switch {
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return ErrNotFound
+	case status == http.StatusForbidden || IsThrottled(status):
+		return ErrBlocked
+	default:
+		return nil
+	}
+}
+
+// throttledError is a 429 or 503 answer. It is an ErrBlocked that keeps
+// the Retry-After header, so the retry policy pauses the host for as long
+// as the site asked.
+type throttledError struct {
+	status     int
+	retryAfter string
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("%v: HTTP %d", ErrBlocked, e.status)
+}
+
+func (e *throttledError) Unwrap() error { return ErrBlocked }
+
+// blockMarkers are found on captcha and bot challenge pages.
+var blockMarkers = []string{"captcha", "are you a robot", "access denied", "cf-challenge", "unusual traffic"}
+
+// looksBlocked reports whether html is a challenge page rather than the
+// page that was asked for.
+func looksBlocked(html string) bool {
+	html = strings.ToLower(html)
+	for _, marker := range blockMarkers {
+		if strings.Contains(html, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// fixtureBaseURL resolves relative links in saved category pages that
+// have no canonical link.
+const fixtureBaseURL = "https://www.trendyol.com"
+
+// fixtureURL returns the canonical URL a page was saved from, which selects
+// its selector profile.
+func fixtureURL(html []byte) string {
+	if m := canonicalPattern.FindSubmatch(html); m != nil {
+		return string(m[1])
+	}
+	return fixtureBaseURL
+}
+
+var canonicalPattern = regexp.MustCompile(`<link rel="canonical" href="([^"]+)"`)
+
+// FixtureResult is the outcome of checking one saved page against its
+// golden output.
+type FixtureResult struct {
+	Name    string
+	Updated bool
+	Err     error
+}
+
+// CheckFixtures parses every saved page in dir and compares the result
+// with the matching .golden.json file, so selector breakage shows up
+// without a browser or network. The file name prefix picks the parser:
+// product_*.html, category_*.html (subcategories), listing_*.html (product
+// links and result count) or home_*.html (top-level navigation). With
+// update set the golden files are rewritten instead.
+func CheckFixtures(dir string, profiles *Profiles, update bool) ([]FixtureResult, error) {
+	pages, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+	sort.Strings(pages)
+
+	var results []FixtureResult
+	for _, page := range pages {
+		result := FixtureResult{Name: filepath.Base(page)}
+		result.Updated, result.Err = checkFixture(page, profiles, update)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func checkFixture(page string, profiles *Profiles, update bool) (bool, error) {
+	html, err := os.ReadFile(page)
+	if err != nil {
+		return false, err
+	}
+
+	var parsed interface{}
+	name := filepath.Base(page)
+	switch {
+	case strings.HasPrefix(name, "product_"):
+		parsed, err = ParseProductPage(html, "", profiles)
+	case strings.HasPrefix(name, "category_"):
+		parsed, err = ParseSubcategories(html, fixtureURL(html), profiles)
+	case strings.HasPrefix(name, "listing_"):
+		parsed, err = ParseListingPage(html, fixtureURL(html), profiles)
+	case strings.HasPrefix(name, "home_"):
+		parsed, err = ParseTopCategories(html, fixtureURL(html), profiles)
+	default:
+		return false, fmt.Errorf("unknown fixture kind (want product_, category_, listing_ or home_ prefix)")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	got, err := json.MarshalIndent(parsed, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	got = append(got, '\n')
+
+	golden := strings.TrimSuffix(page, ".html") + ".golden.json"
+	if update {
+		return true, os.WriteFile(golden, got, 0644)
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		return false, fmt.Errorf("missing golden file, run with --update to create it: %w", err)
+	}
+	if !bytes.Equal(got, want) {
+		return false, fmt.Errorf("parsed output differs from %s:\n%s", filepath.Base(golden), firstDiff(want, got))
+	}
+	return false, nil
+}
+
+// firstDiff shows the first differing line of two golden outputs.
+func firstDiff(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("  line %d\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package scraper
+
+import (
+	"net/http"
+	"sync/atomic"
+	"trendyol-scraper/config"
+)
+
+// Identities rotates the user agent and header profiles from config, so
+// consecutive sessions and requests do not all look alike.
+type Identities struct {
+	list []config.Identity
+	next atomic.Uint64
+}
+
+// NewIdentities uses scraper.identities, or scraper.user_agent alone when
+// no identities are configured.
+func NewIdentities(cfg *config.Config) *Identities {
+	list := cfg.Scraper.Identities
+	if len(list) == 0 {
+		list = []config.Identity{{UserAgent: cfg.Scraper.UserAgent}}
+	}
+	return &Identities{list: list}
+}
+
+// Next returns the following identity in turn.
+func (i *Identities) Next() config.Identity {
+	n := i.next.Add(1) - 1
+	return i.list[n%uint64(len(i.list))]
+}
+
+// apply sets the identity's user agent and headers on req.
+func apply(identity config.Identity, req *http.Request) {
+	for k, v := range identity.Headers {
+		req.Header.Set(k, v)
+	}
+	if identity.UserAgent != "" {
+		req.Header.Set("User-Agent", identity.UserAgent)
+	}
+}
@@ -0,0 +1,174 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+	"trendyol-scraper/config"
+	"trendyol-scraper/models"
+)
+
+// maxImageBytes bounds a single download.
+const maxImageBytes = 20 << 20
+
+// ImageDownloader keeps a local copy of product images. Files are named by
+// the SHA-256 of their content, so an image shared by several products or
+// served under several URLs is stored once.
+type ImageDownloader struct {
+	dir        string
+	httpClient *http.Client
+	limiter    *RateLimiter
+	identities *Identities
+	proxies    *ProxyPool
+}
+
+// NewImageDownloader returns nil when scraper.images.download is off.
+// Downloads rotate through proxies like the API client's requests.
+func NewImageDownloader(cfg *config.Config, limiter *RateLimiter, proxies *ProxyPool) *ImageDownloader {
+	if !cfg.Scraper.Images.Download {
+		return nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFromContext
+	return &ImageDownloader{
+		dir:        cfg.Scraper.Images.Dir,
+		httpClient: &http.Client{Timeout: time.Duration(cfg.Scraper.Images.TimeoutSeconds) * time.Second, Transport: transport},
+		limiter:    limiter,
+		identities: NewIdentities(cfg),
+		proxies:    proxies,
+	}
+}
+
+// Download fetches the images of product that have no local copy yet and
+// sets their Path and Hash. An image that fails is logged and left without
+// a copy; the product is kept either way.
+func (d *ImageDownloader) Download(ctx context.Context, product *models.Product) {
+	for i := range product.Images {
+		image := &product.Images[i]
+		if image.Path != "" {
+			continue
+		}
+		if err := d.fetch(ctx, image); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to download image %s of product %d: %v", image.URL, product.ID, err)
+		}
+	}
+}
+
+func (d *ImageDownloader) fetch(ctx context.Context, image *models.Image) error {
+	if err := d.limiter.Wait(ctx, image.URL); err != nil {
+		return err
+	}
+
+	proxy, err := d.proxies.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(withProxy(ctx, proxy), http.MethodGet, image.URL, nil)
+	if err != nil {
+		return err
+	}
+	apply(d.identities.Next(), req)
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		if isProxyError(err) {
+			d.proxies.Fail(proxy)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if statusError(resp.StatusCode) == ErrBlocked {
+			d.proxies.Cooldown(proxy)
+		}
+		if statusErr := statusError(resp.StatusCode); statusErr != nil {
+			return fmt.Errorf("%w: HTTP %d", statusErr, resp.StatusCode)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxImageBytes {
+		return fmt.Errorf("image is larger than %d bytes", maxImageBytes)
+	}
+	d.limiter.Success(image.URL)
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	name := filepath.Join(hash[:2], hash+imageExtension(body))
+	if err := d.store(name, body); err != nil {
+		return err
+	}
+
+	image.Hash = hash
+	image.Path = filepath.ToSlash(name)
+	return nil
+}
+
+// store writes data to name under the image directory unless a file with
+// the same content is already there.
+func (d *ImageDownloader) store(name string, data []byte) error {
+	target := filepath.Join(d.dir, name)
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+
+	// Workers storing the same content each write their own temporary file;
+	// the renames replace the target with identical bytes
+	tmp, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	return nil
+}
+
+// imageExtension picks the extension from the content itself, so the same
+// bytes always get the same file name whatever URL they came from.
+func imageExtension(data []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	switch mediaType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	case "image/avif":
+		return ".avif"
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package scraper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStoreConcurrently(t *testing.T) {
+	d := &ImageDownloader{dir: t.TempDir()}
+	data := bytes.Repeat([]byte("jpeg"), 64<<10)
+
+	// The workers start together, so all of them find no file yet
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, 16)
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs <- d.store("ab/abcdef.jpg", data)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	got, err := os.ReadFile(filepath.Join(d.dir, "ab", "abcdef.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("stored %d bytes, want %d", len(got), len(data))
+	}
+	entries, err := os.ReadDir(filepath.Join(d.dir, "ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("image directory holds %d files, want only the image", len(entries))
+	}
+}
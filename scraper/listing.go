@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"trendyol-scraper/models"
+)
+
+// listingResponse is the envelope of the listing/search JSON payload, the
+// same shape as the saved data.json.
+type listingResponse struct {
+	Data struct {
+		Contents   []models.Product `json:"contents"`
+		TotalCount int              `json:"totalCount"`
+	} `json:"data"`
+	StatusCode int  `json:"statusCode"`
+	IsSuccess  bool `json:"isSuccess"`
+}
+
+// rawListing is the payload again with every product left undecoded, for
+// Product.Raw and the fields the product model names differently.
+type rawListing struct {
+	Data struct {
+		Contents []json.RawMessage `json:"contents"`
+	} `json:"data"`
+}
+
+type listingItem struct {
+	Category struct {
+		ID int `json:"id"`
+	} `json:"category"`
+}
+
+func decodeListing(data []byte) (*listingResponse, error) {
+	var resp listingResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode listing payload: %w", err)
+	}
+
+	var raw rawListing
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw.Data.Contents) != len(resp.Data.Contents) {
+		return &resp, nil
+	}
+	for i, content := range raw.Data.Contents {
+		p := &resp.Data.Contents[i]
+		p.Raw = content
+
+		var item listingItem
+		if err := json.Unmarshal(content, &item); err == nil && item.Category.ID != 0 {
+			id := strconv.Itoa(item.Category.ID)
+			p.CategoryID = &id
+		}
+	}
+	return &resp, nil
+}
+
+// normalizeProduct makes a product from the listing payload ready for
+// storage: timestamps get a timezone, flags derived from the payload are
+// set, relative URLs are made absolute and the listing image becomes the
+// product's main image.
+func normalizeProduct(p *models.Product, baseURL string) {
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	} else {
+		// Add UTC timezone if not present
+		p.CreatedAt = asUTC(p.CreatedAt)
+	}
+
+	if p.UpdatedAt.IsZero() {
+		p.UpdatedAt = p.CreatedAt
+	} else {
+		// Add UTC timezone if not present
+		p.UpdatedAt = asUTC(p.UpdatedAt)
+	}
+
+	// Note: No need to process promotions as CustomTime already handles timezone
+
+	p.OnFlashSale = p.FlashSale.Active()
+
+	if baseURL != "" && strings.HasPrefix(p.URL, "/") {
+		p.URL = strings.TrimRight(baseURL, "/") + p.URL
+	}
+
+	// Listing images are served from the CDN, so only absolute URLs are
+	// usable
+	if len(p.Images) == 0 && (strings.HasPrefix(p.ImageURL, "https://") || strings.HasPrefix(p.ImageURL, "http://")) {
+		p.Images = []models.Image{newImage(p.ID, 0, p.ImageURL)}
+	}
+}
+
+func asUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
@@ -0,0 +1,118 @@
+package scraper
+
+import (
+	"encoding/json"
+	"testing"
+	"trendyol-scraper/models"
+)
+
+func TestDecodeListing(t *testing.T) {
+	payload := `{"data":{"totalCount":3,"contents":[
+	{"id":1,"name":"Kılıf","webBrand":"Choice","listingId":"84c9","itemNumber":1253464244,
+	 "category":{"name":"Kılıf","id":1108},"categoryId":"999",
+	 "price":{"sellingPrice":12.28,"discountedPrice":9.82,"originalPrice":9.82,"currency":"AED"},
+	 "flashSale":{"newStructureEnabled":false},"cardType":"PRODUCT"},
+	{"id":2,"name":"Kapak","category":{"name":"","id":0},"categoryId":12},
+	{"id":3,"name":"Kutu"}
+	]},"statusCode":200,"isSuccess":true}`
+
+	listing, err := decodeListing([]byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listing.Data.TotalCount != 3 || listing.StatusCode != 200 || !listing.IsSuccess {
+		t.Errorf("envelope = %d products, status %d, success %v", listing.Data.TotalCount, listing.StatusCode, listing.IsSuccess)
+	}
+	products := listing.Data.Contents
+	if len(products) != 3 {
+		t.Fatalf("%d products, want 3", len(products))
+	}
+
+	// The category object wins over categoryId; without it the product's
+	// own categoryId is kept
+	for i, want := range []string{"1108", "12", ""} {
+		got := ""
+		if products[i].CategoryID != nil {
+			got = *products[i].CategoryID
+		}
+		if got != want {
+			t.Errorf("product %d category = %q, want %q", products[i].ID, got, want)
+		}
+	}
+
+	p := products[0]
+	if p.WebBrand != "Choice" || p.ListingID != "84c9" || p.ItemNumber != 1253464244 {
+		t.Errorf("listing fields = %q %q %d", p.WebBrand, p.ListingID, p.ItemNumber)
+	}
+	if want := (models.Money{Amount: 982, Currency: "AED"}); p.Price.DiscountedPrice != want {
+		t.Errorf("discounted price = %v, want %v", p.Price.DiscountedPrice, want)
+	}
+
+	// Raw is the item as sent, fields the model does not know included
+	var raw map[string]any
+	if err := json.Unmarshal(p.Raw, &raw); err != nil {
+		t.Fatalf("raw = %s: %v", p.Raw, err)
+	}
+	if raw["cardType"] != "PRODUCT" || raw["categoryId"] != "999" {
+		t.Errorf("raw = %s, want the whole item", p.Raw)
+	}
+	if string(products[2].Raw) != `{"id":3,"name":"Kutu"}` {
+		t.Errorf("raw = %s", products[2].Raw)
+	}
+}
+
+func TestDecodeListingErrors(t *testing.T) {
+	for _, payload := range []string{
+		`<html>challenge</html>`,
+		`{"data":{"contents":[{"id":"one"}]}}`,
+		`{"data":{"contents":[{"id":1,"categoryId":true}]}}`,
+	} {
+		if _, err := decodeListing([]byte(payload)); err == nil {
+			t.Errorf("decodeListing(%s) succeeded", payload)
+		}
+	}
+}
+
+func TestNormalizeProductImage(t *testing.T) {
+	const main = "https://cdn.dsmcdn.com/mnresize/400/600/ty1625/prod/1_org_zoom.jpg"
+	tests := []struct {
+		name    string
+		product models.Product
+		want    []models.Image
+	}{
+		{
+			name:    "listing image",
+			product: models.Product{ID: 7, ImageURL: main},
+			want:    []models.Image{{ProductID: 7, URL: main, Width: 400, Height: 600}},
+		},
+		{
+			name:    "no image",
+			product: models.Product{ID: 7},
+		},
+		{
+			name:    "relative image",
+			product: models.Product{ID: 7, ImageURL: "/ty1625/prod/1_org_zoom.jpg"},
+		},
+		{
+			name: "gallery kept",
+			product: models.Product{ID: 7, ImageURL: main, Images: []models.Image{
+				{ProductID: 7, URL: main}, {ProductID: 7, Position: 1, URL: "https://cdn.dsmcdn.com/2.jpg"},
+			}},
+			want: []models.Image{{ProductID: 7, URL: main}, {ProductID: 7, Position: 1, URL: "https://cdn.dsmcdn.com/2.jpg"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.product
+			normalizeProduct(&p, "https://www.trendyol.com")
+			if len(p.Images) != len(tt.want) {
+				t.Fatalf("images = %+v, want %+v", p.Images, tt.want)
+			}
+			for i := range tt.want {
+				if p.Images[i] != tt.want[i] {
+					t.Errorf("image %d = %+v, want %+v", i, p.Images[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -1,10 +1,8 @@
 package scraper
 
 import (
-	"encoding/json"
-	"io/ioutil"
 	"log"
-	"time"
+	"os"
 	"trendyol-scraper/models"
 )
 
@@ -17,62 +15,23 @@ func NewMockProcessor(filePath string) *MockProcessor {
 }
 
 func (mp *MockProcessor) ProcessMockData() ([]models.Product, error) {
-	data, err := ioutil.ReadFile(mp.filePath)
+	data, err := os.ReadFile(mp.filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	var mockData struct {
-		Data struct {
-			Contents []models.Product `json:"contents"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(data, &mockData); err != nil {
+	mockData, err := decodeListing(data)
+	if err != nil {
 		return nil, err
 	}
 
 	// Process products to match our database model
 	var products []models.Product
 	for _, p := range mockData.Data.Contents {
-		// Ensure CreatedAt and UpdatedAt have timezone information
-		if p.CreatedAt.IsZero() {
-			p.CreatedAt = time.Now()
-		} else {
-			// Add UTC timezone if not present
-			p.CreatedAt = time.Date(
-				p.CreatedAt.Year(),
-				p.CreatedAt.Month(),
-				p.CreatedAt.Day(),
-				p.CreatedAt.Hour(),
-				p.CreatedAt.Minute(),
-				p.CreatedAt.Second(),
-				p.CreatedAt.Nanosecond(),
-				time.UTC,
-			)
-		}
-
-		if p.UpdatedAt.IsZero() {
-			p.UpdatedAt = p.CreatedAt
-		} else {
-			// Add UTC timezone if not present
-			p.UpdatedAt = time.Date(
-				p.UpdatedAt.Year(),
-				p.UpdatedAt.Month(),
-				p.UpdatedAt.Day(),
-				p.UpdatedAt.Hour(),
-				p.UpdatedAt.Minute(),
-				p.UpdatedAt.Second(),
-				p.UpdatedAt.Nanosecond(),
-				time.UTC,
-			)
-		}
-
-		// Note: No need to process promotions as CustomTime already handles timezone
-
+		normalizeProduct(&p, "")
 		products = append(products, p)
 	}
 
 	log.Printf("Processed %d products from mock data", len(products))
 	return products, nil
-}
\ No newline at end of file
+}
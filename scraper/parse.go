@@ -0,0 +1,502 @@
+package scraper
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"trendyol-scraper/config"
+	"trendyol-scraper/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ProductPage is everything extracted from a product detail page. Fields
+// the product model does not store yet are kept alongside it.
+type ProductPage struct {
+	Product     models.Product `json:"product"`
+	Description string         `json:"description"`
+}
+
+var productIDPattern = regexp.MustCompile(`-p-(\d+)`)
+
+// productIDFrom returns the number of the -p-<id> part of a product URL,
+// or 0.
+func productIDFrom(rawURL string) int {
+	if m := productIDPattern.FindStringSubmatch(rawURL); len(m) > 1 {
+		if id, err := strconv.Atoi(m[1]); err == nil {
+			return id
+		}
+	}
+	return 0
+}
+
+// ParseProductPage extracts a product from saved or live page HTML using
+// the selector profile for the page. If pageURL is empty the canonical link
+// of the page is used instead.
+func ParseProductPage(html []byte, pageURL string, profiles *Profiles) (*ProductPage, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse product page: %w", err)
+	}
+
+	if pageURL == "" {
+		pageURL, _ = doc.Find(`link[rel="canonical"]`).Attr("href")
+	}
+
+	profile := profiles.For(pageURL)
+	sel := profile.Selectors
+
+	page := &ProductPage{}
+	product := &page.Product
+	product.URL = pageURL
+	product.Name = text(doc, sel.ProductName)
+	product.Brand = text(doc, sel.Brand)
+	product.Rating.AverageRating = parseRatingWidth(doc.Find(sel.Rating).AttrOr("style", ""))
+	page.Description = cleanLines(doc.Find(sel.Description).First().Text())
+
+	if product.Name == "" {
+		return nil, fmt.Errorf("%w: product name not found (profile %s, selector %s)", ErrLayoutChanged, profile.Name, sel.ProductName)
+	}
+
+	// Extract ID from URL, or from the canonical link when the page was
+	// reached through another URL. Variants and images reference the
+	// product, so a page without an ID is not a product we can store.
+	canonical, _ := doc.Find(`link[rel="canonical"]`).Attr("href")
+	product.ID = productIDFrom(pageURL)
+	if product.ID == 0 {
+		product.ID = productIDFrom(canonical)
+	}
+	if product.ID == 0 {
+		return nil, fmt.Errorf("%w: no product ID in %s", ErrNotFound, pageURL)
+	}
+	if u, err := url.Parse(pageURL); err == nil {
+		product.BoutiqueID, _ = strconv.Atoi(u.Query().Get("boutiqueId"))
+		product.MerchantID, _ = strconv.Atoi(u.Query().Get("merchantId"))
+	}
+
+	currency := profile.NumberFormat.Currency
+	if price, ok := parsePrice(text(doc, sel.Price), profile.NumberFormat); ok {
+		product.Price.SellingPrice = price
+		product.Price.DiscountedPrice = price
+	}
+	if price, ok := parsePrice(text(doc, sel.OriginalPrice), profile.NumberFormat); ok {
+		product.Price.OriginalPrice = price
+	}
+
+	// Structured data wins over the visible price when present
+	if offer := findOffer(doc); offer != nil {
+		if price, ok := offer.price(); ok {
+			product.Price.SellingPrice = price
+			product.Price.DiscountedPrice = price
+		}
+		if offer.PriceCurrency != "" {
+			currency = offer.PriceCurrency
+		}
+	}
+	product.Price.SetCurrency(currency)
+
+	seen := make(map[string]bool)
+	doc.Find(sel.Images).Each(func(_ int, img *goquery.Selection) {
+		// Lazy loaded images keep the real URL in data-src
+		src := img.AttrOr("src", "")
+		if src == "" || strings.HasPrefix(src, "data:") {
+			src = img.AttrOr("data-src", "")
+		}
+		if src == "" || seen[src] {
+			return
+		}
+		seen[src] = true
+		product.Images = append(product.Images, newImage(product.ID, len(product.Images), src))
+	})
+	if len(product.Images) > 0 {
+		product.ImageURL = product.Images[0].URL
+	}
+
+	product.Variants = findVariants(doc, product)
+	if len(product.Variants) == 0 {
+		doc.Find(sel.Variants).Each(func(_ int, el *goquery.Selection) {
+			value := strings.TrimSpace(el.Text())
+			if value == "" {
+				return
+			}
+			product.Variants = append(product.Variants, models.Variant{
+				ProductID: product.ID,
+				SKU:       fmt.Sprintf("%d-%s", product.ID, value),
+				Name:      value,
+				Value:     value,
+				Currency:  product.Price.Currency,
+				Available: sel.VariantSoldOut == "" || !el.Is(sel.VariantSoldOut),
+			})
+		})
+	}
+
+	return page, nil
+}
+
+// ListingLinks is what a category or search listing page in the browser
+// yields: the product links and, when the page states it, the total number
+// of products in the listing.
+type ListingLinks struct {
+	Links      []string `json:"links"`
+	TotalCount int      `json:"total_count"`
+}
+
+// ParseListingPage extracts the product links and result count of a
+// listing page. A page without products is not an error.
+func ParseListingPage(html []byte, pageURL string, profiles *Profiles) (*ListingLinks, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse listing page: %w", err)
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL %q: %w", pageURL, err)
+	}
+
+	profile := profiles.For(pageURL)
+	listing := &ListingLinks{
+		TotalCount: parseCount(text(doc, profile.Selectors.ResultCount), profile.NumberFormat),
+	}
+
+	seen := make(map[string]bool)
+	doc.Find(profile.Selectors.ProductLinks).Each(func(_ int, a *goquery.Selection) {
+		href, ok := a.Attr("href")
+		if !ok || href == "" {
+			return
+		}
+		ref, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+		link := base.ResolveReference(ref).String()
+		if !seen[link] {
+			seen[link] = true
+			listing.Links = append(listing.Links, link)
+		}
+	})
+
+	return listing, nil
+}
+
+// ParseTopCategories extracts the top-level categories from the home page
+// navigation.
+func ParseTopCategories(html []byte, pageURL string, profiles *Profiles) ([]models.Category, error) {
+	return parseCategoryLinks(html, pageURL, profiles.For(pageURL).Selectors.TopCategories)
+}
+
+// ParseSubcategories extracts the child categories listed on a category
+// page.
+func ParseSubcategories(html []byte, pageURL string, profiles *Profiles) ([]models.Category, error) {
+	return parseCategoryLinks(html, pageURL, profiles.For(pageURL).Selectors.Subcategories)
+}
+
+func parseCategoryLinks(html []byte, baseURL, selector string) ([]models.Category, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse category page: %w", err)
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+
+	var categories []models.Category
+	doc.Find(selector).Each(func(_ int, a *goquery.Selection) {
+		name := strings.TrimSpace(a.Text())
+		href, ok := a.Attr("href")
+		if name == "" || !ok || href == "" {
+			return
+		}
+		ref, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+		link := base.ResolveReference(ref).String()
+		categories = append(categories, models.Category{
+			ID:   CategoryID(link),
+			Name: name,
+			URL:  link,
+		})
+	})
+
+	return categories, nil
+}
+
+var categoryIDPattern = regexp.MustCompile(`-c(\d+)$`)
+
+// CategoryID derives a stable ID for the category at rawURL: the site's own
+// category number from the -c<id> path suffix or the wc parameter, which
+// is also what the listing payload reports for a product, or else "u-" and
+// a hash of the URL without its query.
+func CategoryID(rawURL string) string {
+	key := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path := strings.TrimRight(u.Path, "/")
+		if m := categoryIDPattern.FindStringSubmatch(path); len(m) > 1 {
+			return m[1]
+		}
+		if wc := u.Query().Get("wc"); wc != "" {
+			if _, err := strconv.Atoi(wc); err == nil {
+				return wc
+			}
+		}
+		key = strings.ToLower(u.Host + path)
+	}
+	sum := sha1.Sum([]byte(key))
+	return "u-" + hex.EncodeToString(sum[:8])
+}
+
+func text(doc *goquery.Document, selector string) string {
+	return strings.TrimSpace(doc.Find(selector).First().Text())
+}
+
+// cleanLines trims every line and drops empty ones, similar to innerText.
+func cleanLines(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parsePrice reads a price written in the profile's number format, such
+// as "1.299,90 TL" or "1,299.90 AED".
+func parsePrice(s string, nf config.NumberFormat) (models.Money, bool) {
+	for _, symbol := range nf.CurrencySymbols {
+		s = strings.ReplaceAll(s, symbol, "")
+	}
+	s = strings.Join(strings.Fields(s), "")
+	if nf.ThousandsSeparator != "" {
+		s = strings.ReplaceAll(s, nf.ThousandsSeparator, "")
+	}
+	if nf.DecimalSeparator != "" && nf.DecimalSeparator != "." {
+		s = strings.ReplaceAll(s, nf.DecimalSeparator, ".")
+	}
+	if s == "" {
+		return models.Money{}, false
+	}
+	price, err := models.ParseMoney(s, nf.Currency)
+	if err != nil {
+		return models.Money{}, false
+	}
+	return price, true
+}
+
+var countNumber = regexp.MustCompile(`\d[\d.,\s]*`)
+
+// parseCount reads the first whole number in s, such as the 10.000 in
+// "'kulaklık' araması için 10.000+ sonuç listeleniyor". It returns 0 when
+// there is none.
+func parseCount(s string, nf config.NumberFormat) int {
+	m := countNumber.FindString(s)
+	if nf.DecimalSeparator != "" {
+		m, _, _ = strings.Cut(m, nf.DecimalSeparator)
+	}
+	m = strings.Join(strings.Fields(m), "")
+	if nf.ThousandsSeparator != "" {
+		m = strings.ReplaceAll(m, nf.ThousandsSeparator, "")
+	}
+	n, err := strconv.Atoi(m)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+var resizePattern = regexp.MustCompile(`/mnresize/(\d+)/(\d+)/`)
+
+// newImage describes the image at src, taking its size from the CDN resize
+// path such as /mnresize/1200/1800/.
+func newImage(productID, position int, src string) models.Image {
+	image := models.Image{ProductID: productID, Position: position, URL: src}
+	if m := resizePattern.FindStringSubmatch(src); len(m) > 2 {
+		image.Width, _ = strconv.Atoi(m[1])
+		image.Height, _ = strconv.Atoi(m[2])
+	}
+	return image
+}
+
+var leadingNumber = regexp.MustCompile(`[\d.]+`)
+
+// parseRatingWidth converts the rating bar width ("width: 84%") to stars.
+func parseRatingWidth(style string) float64 {
+	m := leadingNumber.FindString(style)
+	if m == "" {
+		return 0
+	}
+	width, err := strconv.ParseFloat(m, 64)
+	if err != nil {
+		return 0
+	}
+	return width / 20
+}
+
+type ldOffer struct {
+	Price         json.RawMessage `json:"price"`
+	PriceCurrency string          `json:"priceCurrency"`
+}
+
+// price accepts both "129.90" and 129.90, which the site uses interchangeably.
+func (o *ldOffer) price() (models.Money, bool) {
+	raw := strings.Trim(string(o.Price), `"`)
+	if raw == "" {
+		return models.Money{}, false
+	}
+	price, err := models.ParseMoney(raw, o.PriceCurrency)
+	return price, err == nil
+}
+
+// findOffer returns the offer of the first Product in the page's
+// application/ld+json blocks.
+func findOffer(doc *goquery.Document) *ldOffer {
+	var offer *ldOffer
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, script *goquery.Selection) bool {
+		var raw json.RawMessage = []byte(script.Text())
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			items = []json.RawMessage{raw}
+		}
+
+		for _, item := range items {
+			var ld struct {
+				Type   string          `json:"@type"`
+				Offers json.RawMessage `json:"offers"`
+			}
+			if err := json.Unmarshal(item, &ld); err != nil || ld.Type != "Product" || len(ld.Offers) == 0 {
+				continue
+			}
+
+			var o ldOffer
+			if err := json.Unmarshal(ld.Offers, &o); err != nil {
+				var list []ldOffer
+				if err := json.Unmarshal(ld.Offers, &list); err != nil || len(list) == 0 {
+					continue
+				}
+				o = list[0]
+			}
+			offer = &o
+			return false
+		}
+		return true
+	})
+	return offer
+}
+
+// productState is the part of the state the product page embeds in
+// window.__PRODUCT_DETAIL_APP_INITIAL_STATE__ that lists its variants.
+type productState struct {
+	Product struct {
+		Variants []struct {
+			AttributeName  string `json:"attributeName"`
+			AttributeValue string `json:"attributeValue"`
+			ItemNumber     int64  `json:"itemNumber"`
+			Barcode        string `json:"barcode"`
+			Stock          *int   `json:"stock"`
+			InStock        bool   `json:"inStock"`
+			Price          struct {
+				DiscountedPrice struct {
+					Value models.Money `json:"value"`
+				} `json:"discountedPrice"`
+				Currency string `json:"currency"`
+			} `json:"price"`
+		} `json:"variants"`
+		AllVariants []struct {
+			Value      string       `json:"value"`
+			ItemNumber int64        `json:"itemNumber"`
+			Barcode    string       `json:"barcode"`
+			InStock    bool         `json:"inStock"`
+			Price      models.Money `json:"price"`
+			Currency   string       `json:"currency"`
+		} `json:"allVariants"`
+	} `json:"product"`
+}
+
+const productStateMarker = "__PRODUCT_DETAIL_APP_INITIAL_STATE__"
+
+// findVariants reads the variants of product from the page state, with
+// price and stock per variant. It returns nil when the page has no state,
+// and the variant buttons are read instead.
+func findVariants(doc *goquery.Document, product *models.Product) []models.Variant {
+	var state productState
+	found := false
+	doc.Find("script").EachWithBreak(func(_ int, script *goquery.Selection) bool {
+		_, rest, ok := strings.Cut(script.Text(), productStateMarker)
+		if !ok {
+			return true
+		}
+		_, rest, ok = strings.Cut(rest, "=")
+		if !ok {
+			return true
+		}
+		// The object is followed by more script, which the decoder leaves
+		found = json.NewDecoder(strings.NewReader(rest)).Decode(&state) == nil
+		return !found
+	})
+	if !found {
+		return nil
+	}
+
+	// allVariants lists every option; variants adds the attribute name and
+	// stock for the options it covers
+	var attribute string
+	stock := make(map[int64]*int)
+	for _, v := range state.Product.Variants {
+		if attribute == "" {
+			attribute = v.AttributeName
+		}
+		stock[v.ItemNumber] = v.Stock
+	}
+
+	variant := func(value string, itemNumber int64, barcode string, price models.Money, currency string, inStock bool, quantity *int) models.Variant {
+		v := models.Variant{
+			ProductID: product.ID,
+			SKU:       barcode,
+			Name:      value,
+			Attribute: attribute,
+			Value:     value,
+			Price:     price,
+			Currency:  currency,
+			Available: inStock,
+		}
+		if v.SKU == "" && itemNumber != 0 {
+			v.SKU = strconv.FormatInt(itemNumber, 10)
+		}
+		if v.SKU == "" {
+			v.SKU = fmt.Sprintf("%d-%s", product.ID, value)
+		}
+		if attribute != "" {
+			v.Name = attribute + ": " + value
+		}
+		if v.Currency == "" {
+			v.Currency = product.Price.Currency
+		}
+		v.Price = v.Price.WithCurrency(v.Currency)
+		if quantity != nil {
+			v.Stock = *quantity
+		}
+		return v
+	}
+
+	var variants []models.Variant
+	if len(state.Product.AllVariants) > 0 {
+		for _, v := range state.Product.AllVariants {
+			variants = append(variants, variant(v.Value, v.ItemNumber, v.Barcode, v.Price, v.Currency, v.InStock, stock[v.ItemNumber]))
+		}
+		return variants
+	}
+	for _, v := range state.Product.Variants {
+		variants = append(variants, variant(v.AttributeValue, v.ItemNumber, v.Barcode, v.Price.DiscountedPrice.Value, v.Price.Currency, v.InStock, v.Stock))
+	}
+	return variants
+}
@@ -0,0 +1,76 @@
+package scraper
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"path/filepath"
+	"testing"
+	"trendyol-scraper/config"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/pages")
+
+// TestFixtures parses the saved pages with the built-in profiles and
+// compares them with their golden output, like `fixtures check`.
+func TestFixtures(t *testing.T) {
+	pages, err := filepath.Glob(filepath.Join("testdata", "pages", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) == 0 {
+		t.Fatal("no fixtures in testdata/pages")
+	}
+
+	profiles := NewProfiles(&config.Config{})
+	for _, page := range pages {
+		t.Run(filepath.Base(page), func(t *testing.T) {
+			if _, err := checkFixture(page, profiles, *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestParseProductPageWithoutID(t *testing.T) {
+	html := []byte(`<html><head><link rel="canonical" href="https://www.trendyol.com/apple/airpods-kilif-p-42"></head>
+<body><div class="pr-new-br"><span>AirPods Kılıf</span></div><span class="prc-dsc">149,90 TL</span></body></html>`)
+	profiles := NewProfiles(&config.Config{})
+
+	page, err := ParseProductPage(html, "https://www.trendyol.com/sr?q=airpods", profiles)
+	if err != nil {
+		t.Fatalf("canonical link fallback: %v", err)
+	}
+	if page.Product.ID != 42 {
+		t.Errorf("ID = %d, want 42 from the canonical link", page.Product.ID)
+	}
+
+	noCanonical := bytes.Replace(html, []byte("-p-42"), nil, 1)
+	if _, err := ParseProductPage(noCanonical, "https://www.trendyol.com/sr?q=airpods", profiles); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound for a page without product ID", err)
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	tr := config.NumberFormat{DecimalSeparator: ",", ThousandsSeparator: "."}
+	en := config.NumberFormat{DecimalSeparator: ".", ThousandsSeparator: ","}
+	tests := []struct {
+		in   string
+		nf   config.NumberFormat
+		want int
+	}{
+		{"1.234 sonuç listeleniyor", tr, 1234},
+		{"\"ayakkabı\" araması için 12.345.678 sonuç", tr, 12345678},
+		{"1,234 results", en, 1234},
+		{"Showing 1 234 products", en, 1234},
+		{"100+ ürün", tr, 100},
+		{"2,5 bin", tr, 2},
+		{"no results", tr, 0},
+		{"", en, 0},
+	}
+	for _, tt := range tests {
+		if got := parseCount(tt.in, tt.nf); got != tt.want {
+			t.Errorf("parseCount(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+)
+
+// poolResult is the outcome for the input at the same index.
+type poolResult[R any] struct {
+	Value R
+	Err   error
+}
+
+// runPool calls fn for every input with at most workers calls in flight and
+// returns the results in input order. Inputs not yet started when ctx is
+// cancelled report ctx.Err(). The worker index lets callers keep
+// per-worker resources such as browser tabs; timeouts are left to fn so
+// that rate limit waits are not counted against them.
+func runPool[T, R any](ctx context.Context, workers int, inputs []T, fn func(ctx context.Context, worker int, in T) (R, error)) []poolResult[R] {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(inputs) {
+		workers = len(inputs)
+	}
+
+	results := make([]poolResult[R], len(inputs))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range jobs {
+				value, err := fn(ctx, worker, inputs[i])
+				results[i] = poolResult[R]{Value: value, Err: err}
+			}
+		}(w)
+	}
+
+	next := 0
+dispatch:
+	for ; next < len(inputs); next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i := next; i < len(inputs); i++ {
+		results[i].Err = ctx.Err()
+	}
+	return results
+}
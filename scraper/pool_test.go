@@ -0,0 +1,119 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPoolOrderAndLimit(t *testing.T) {
+	tests := []struct {
+		workers, inputs, wantMax int
+	}{
+		{workers: 4, inputs: 20, wantMax: 4},
+		{workers: 1, inputs: 5, wantMax: 1},
+		{workers: 0, inputs: 5, wantMax: 1}, // at least one worker
+		{workers: 8, inputs: 3, wantMax: 3}, // no more workers than inputs
+		{workers: 4, inputs: 0, wantMax: 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d workers %d inputs", tt.workers, tt.inputs), func(t *testing.T) {
+			inputs := make([]int, tt.inputs)
+			for i := range inputs {
+				inputs[i] = i
+			}
+
+			var inFlight, maxInFlight atomic.Int32
+			results := runPool(context.Background(), tt.workers, inputs, func(ctx context.Context, worker, in int) (string, error) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					m := maxInFlight.Load()
+					if n <= m || maxInFlight.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				if worker < 0 || worker >= max(tt.workers, 1) {
+					t.Errorf("worker index %d out of range", worker)
+				}
+				// Later inputs finish first so the order has to be restored
+				time.Sleep(time.Duration(len(inputs)-in) * time.Millisecond)
+				if in%3 == 0 {
+					return "", fmt.Errorf("input %d", in)
+				}
+				return fmt.Sprint("r", in), nil
+			})
+
+			if len(results) != len(inputs) {
+				t.Fatalf("got %d results for %d inputs", len(results), len(inputs))
+			}
+			for i, r := range results {
+				if i%3 == 0 {
+					if r.Err == nil || r.Err.Error() != fmt.Sprintf("input %d", i) {
+						t.Errorf("results[%d].Err = %v, want the error of input %d", i, r.Err, i)
+					}
+				} else if r.Err != nil || r.Value != fmt.Sprint("r", i) {
+					t.Errorf("results[%d] = %q, %v, want r%d", i, r.Value, r.Err, i)
+				}
+			}
+			if got := int(maxInFlight.Load()); got > tt.wantMax {
+				t.Errorf("%d calls in flight, want at most %d", got, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestRunPoolCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inputs := make([]int, 50)
+	var calls atomic.Int32
+	results := runPool(ctx, 2, inputs, func(ctx context.Context, _ int, _ int) (int, error) {
+		if calls.Add(1) == 3 {
+			cancel()
+		}
+		return 1, nil
+	})
+
+	// Inputs are handed out in order, so the started ones are a prefix;
+	// the dispatcher may start a few more before it sees the cancellation
+	started := int(calls.Load())
+	if started < 3 || started == len(inputs) {
+		t.Fatalf("%d calls started, want cancel to stop the pool after the 3rd", started)
+	}
+	for i, r := range results {
+		if i < started {
+			if r.Err != nil || r.Value != 1 {
+				t.Errorf("started results[%d] = %d, %v, want the value of fn", i, r.Value, r.Err)
+			}
+		} else if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("results[%d].Err = %v, want context.Canceled for an input never started", i, r.Err)
+		}
+	}
+}
+
+func TestRunPoolCancelledBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls atomic.Int32
+	results := runPool(ctx, 3, []int{1, 2, 3, 4, 5}, func(context.Context, int, int) (int, error) {
+		calls.Add(1)
+		return 0, nil
+	})
+	// The dispatch select may still hand out a job or two before it sees
+	// the cancellation; everything else reports ctx.Err()
+	cancelled := 0
+	for _, r := range results {
+		if errors.Is(r.Err, context.Canceled) {
+			cancelled++
+		}
+	}
+	if cancelled+int(calls.Load()) != len(results) {
+		t.Errorf("%d cancelled + %d called, want %d", cancelled, calls.Load(), len(results))
+	}
+}
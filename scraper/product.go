@@ -4,137 +4,255 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"regexp"
-	"strconv"
 	"time"
 
 	"github.com/chromedp/chromedp"
-	"trendyol-scraper/models"
 	"trendyol-scraper/config"
+	"trendyol-scraper/frontier"
+	"trendyol-scraper/models"
 )
 
 type ProductScraper struct {
-	config *config.Config
+	config     *config.Config
+	api        *APIClient
+	profiles   *Profiles
+	limiter    *RateLimiter
+	retry      *RetryPolicy
+	identities *Identities
+	proxies    *ProxyPool
+	frontier   frontier.Frontier
+	images     *ImageDownloader // nil unless images are downloaded
+	seen       *productSet
+}
+
+func NewProductScraper(cfg *config.Config, limiter *RateLimiter, retry *RetryPolicy, proxies *ProxyPool, f frontier.Frontier) *ProductScraper {
+	return &ProductScraper{
+		config:     cfg,
+		api:        NewAPIClient(cfg, limiter, retry, proxies, f),
+		profiles:   NewProfiles(cfg),
+		limiter:    limiter,
+		retry:      retry,
+		identities: NewIdentities(cfg),
+		proxies:    proxies,
+		frontier:   f,
+		images:     NewImageDownloader(cfg, limiter, proxies),
+		seen:       newProductSet(),
+	}
+}
+
+// ScrapeProductsFromCategory lists a category through the JSON API. The
+// headless browser is only used in browser mode, or as a fallback when the
+// API fails before returning any product and browser_fallback is enabled.
+// Products this scraper already returned for another category or search
+// are left out, and products the payload does not place in a category are
+// linked to this one. On error the products scraped so far are returned
+// with it.
+func (ps *ProductScraper) ScrapeProductsFromCategory(ctx context.Context, categoryURL string) ([]models.Product, error) {
+	products, err := ps.scrapeCategory(ctx, categoryURL)
+	categoryID := CategoryID(categoryURL)
+	for i := range products {
+		if products[i].CategoryID == nil {
+			products[i].CategoryID = &categoryID
+		}
+	}
+	return ps.seen.dedupe(products), err
+}
+
+func (ps *ProductScraper) scrapeCategory(ctx context.Context, categoryURL string) ([]models.Product, error) {
+	if ps.config.Scraper.Mode == "browser" {
+		return ps.scrapeCategoryWithBrowser(ctx, categoryURL)
+	}
+
+	products, err := ps.api.CategoryProducts(ctx, categoryURL)
+	if err == nil || len(products) > 0 || ctx.Err() != nil || !ps.config.Scraper.BrowserFallback {
+		return products, err
+	}
+
+	log.Printf("Listing API failed for %s, falling back to the browser: %v", categoryURL, err)
+	return ps.scrapeCategoryWithBrowser(ctx, categoryURL)
 }
 
-func NewProductScraper(cfg *config.Config) *ProductScraper {
-	return &ProductScraper{config: cfg}
+// SearchProducts lists every product matching query through the JSON API,
+// leaving out products this scraper already returned.
+func (ps *ProductScraper) SearchProducts(ctx context.Context, query string) ([]models.Product, error) {
+	products, err := ps.api.SearchProducts(ctx, query)
+	return ps.seen.dedupe(products), err
 }
 
-func (ps *ProductScraper) ScrapeProductsFromCategory(categoryURL string) ([]models.Product, error) {
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-
-	var products []models.Product
-	page := 1
-
-	for {
-		url := fmt.Sprintf("%s?pi=%d", categoryURL, page)
-		var productLinks []string
-
-		// Extract product links from page
-		err := chromedp.Run(ctx,
-			chromedp.Navigate(url),
-			chromedp.WaitVisible(`.product-card`),
-			chromedp.Evaluate(`
-				Array.from(document.querySelectorAll('.product-card a')).map(a => a.href)
-			`, &productLinks),
-		)
+// scrapeCategoryWithBrowser walks the listing pages in one session,
+// queueing the product links in the frontier, and then scrapes the
+// products in parallel, one session per worker. Paging stops at an empty
+// page, at the result count the first page states, at a page that only
+// repeats earlier products, or at scraper.max_pages, whichever comes
+// first. Listing pages and products
+// are checkpointed separately, so an interrupted crawl skips what is done.
+func (ps *ProductScraper) scrapeCategoryWithBrowser(ctx context.Context, categoryURL string) ([]models.Product, error) {
+	browsers := newBrowsers(ctx, ps.identities, ps.proxies)
+	defer browsers.close()
+
+	timeout := time.Duration(ps.config.Scraper.PageTimeoutSeconds) * time.Second
+	workers := ps.config.Scraper.Workers
+	listingScope := "listing " + categoryURL
+	productScope := "products " + categoryURL
+
+	donePages, err := resume(ctx, ps.frontier, listingScope)
+	if err != nil {
+		return nil, err
+	}
+	doneProducts, err := resume(ctx, ps.frontier, productScope)
+	if err != nil {
+		return nil, err
+	}
+
+	var listErr error
+	var session *browserSession
+	listed := newProductSet()
+	perPage, totalCount := 0, 0
+	maxPages := ps.config.Scraper.MaxPages
+	for page := 1; page <= maxPages; page++ {
+		url := withPage(categoryURL, page)
+		listing, err := visit(ctx, ps.frontier, listingScope, url, donePages, func() (*ListingLinks, error) {
+			return withRetry(ctx, ps.retry, url, func() (*ListingLinks, error) {
+				if err := ps.limiter.Wait(ctx, url); err != nil {
+					return nil, err
+				}
+				var listing *ListingLinks
+				err := browsers.use(&session, func(tabCtx context.Context) error {
+					var err error
+					listing, err = ps.productLinks(tabCtx, url, timeout)
+					return err
+				})
+				return listing, err
+			})
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to scrape product links: %w", err)
+			// Scrape the products found so far and report the error after
+			listErr = err
+			break
 		}
 
-		if len(productLinks) == 0 {
+		if len(listing.Links) == 0 {
 			break // No more products
 		}
 
-		// Scrape each product
-		for _, link := range productLinks {
-			time.Sleep(time.Duration(ps.config.Scraper.DelaySeconds) * time.Second)
-			
-			product, err := ps.scrapeProductPage(ctx, link)
-			if err != nil {
-				log.Printf("Failed to scrape product %s: %v", link, err)
+		// Past the last page the site may serve the last page again
+		var links []string
+		repeated := true
+		for _, link := range listing.Links {
+			key := productKey(link)
+			if !listed.add(key) {
 				continue
 			}
+			repeated = false
+			if !ps.seen.has(key) {
+				links = append(links, link)
+			}
+		}
+		if repeated {
+			log.Printf("Page %d of %s repeats earlier pages, stopping", page, categoryURL)
+			break
+		}
+		if err := ps.frontier.Add(ctx, productScope, 0, links...); err != nil {
+			return nil, err
+		}
 
-			products = append(products, *product)
+		if page == 1 {
+			perPage, totalCount = len(listing.Links), listing.TotalCount
+		}
+		if totalCount > 0 && page*perPage >= totalCount {
+			break // Last page by the stated count
 		}
+		if page == maxPages {
+			log.Printf("Stopping %s at page %d (scraper.max_pages)", categoryURL, page)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// Sessions are opened on first use; each worker only touches its own slot
+	sessions := make([]*browserSession, workers)
+	err = drain(ctx, ps.frontier, productScope, workers, func(ctx context.Context, worker int, e frontier.Entry) (*models.Product, error) {
+		product, err := withRetry(ctx, ps.retry, e.URL, func() (*models.Product, error) {
+			if err := ps.limiter.Wait(ctx, e.URL); err != nil {
+				return nil, err
+			}
+			var product *models.Product
+			err := browsers.use(&sessions[worker], func(tabCtx context.Context) error {
+				var err error
+				product, err = ps.scrapeProductPage(tabCtx, e.URL, timeout)
+				return err
+			})
+			return product, err
+		})
+		// Downloaded with the product, so the checkpoint records the files
+		if err == nil && ps.images != nil {
+			ps.images.Download(ctx, product)
+		}
+		return product, err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		page++
+	products, err := results(ctx, ps.frontier, productScope, doneProducts, func(p *models.Product) {
+		p.Replayed = true
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return products, nil
+	if listErr != nil {
+		return products, fmt.Errorf("failed to scrape product links: %w", listErr)
+	}
+	if err := finish(ctx, ps.frontier, productScope); err != nil {
+		return products, err
+	}
+	return products, finish(ctx, ps.frontier, listingScope)
 }
 
-func (ps *ProductScraper) scrapeProductPage(ctx context.Context, url string) (*models.Product, error) {
-	var product models.Product
-	var rawData map[string]interface{}
-
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(url),
-		chromedp.WaitVisible(`.product-detail`),
-		chromedp.Evaluate(`
-			(() => {
-				const script = document.querySelector('script[type="application/ld+json"]');
-				return script ? JSON.parse(script.innerText) : {};
-			})()
-		`, &rawData),
-		chromedp.Evaluate(`
-			(() => {
-				const product = {
-					name: document.querySelector('.pr-new-br span')?.innerText.trim(),
-					brand: document.querySelector('.merchant-text')?.innerText.trim(),
-					price: parseFloat(document.querySelector('.prc-dsc')?.innerText.replace('TL', '').trim().replace('.', '').replace(',', '.')),
-					originalPrice: parseFloat(document.querySelector('.prc-org')?.innerText.replace('TL', '').trim().replace('.', '').replace(',', '.')) || null,
-					rating: parseFloat(document.querySelector('.rating-line')?.style.width || '0') / 20,
-					images: Array.from(document.querySelectorAll('.gallery-modal-content img')).map(img => img.src),
-					description: document.querySelector('.detail-attr-container')?.innerText.trim()
-				};
-				
-				// Extract variants if available
-				const variants = [];
-				const variantElements = document.querySelectorAll('.variant-selector-item');
-				variantElements.forEach(el => {
-					variants.push({
-						name: el.innerText.trim(),
-						price: product.price, // Default to main price
-						stock: 1 // Default available
-					});
-				});
-				
-				product.variants = variants;
-				return product;
-			})()
-		`, &product),
+// productLinks returns the product URLs and result count of one listing
+// page. It waits for the first product link or for the page to finish
+// loading, so a page past the last one comes back empty instead of timing
+// out.
+func (ps *ProductScraper) productLinks(tabCtx context.Context, url string, timeout time.Duration) (*ListingLinks, error) {
+	selector := ps.profiles.For(url).Selectors.ProductLinks
+	var html string
+	err := loadPage(tabCtx, url, "", timeout,
+		chromedp.Poll(fmt.Sprintf(`
+			document.querySelector(%q) !== null || document.readyState === "complete"
+		`, selector), nil, chromedp.WithPollingTimeout(0)),
+		chromedp.OuterHTML("html", &html),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scrape product page: %w", err)
+		return nil, err
 	}
 
-	// Extract ID from URL
-	re := regexp.MustCompile(`-p-(\d+)`)
-	matches := re.FindStringSubmatch(url)
-	if len(matches) > 1 {
-		id, err := strconv.Atoi(matches[1])
-		if err == nil {
-			product.ID = id
-		}
+	listing, err := ParseListingPage([]byte(html), url, ps.profiles)
+	if err != nil {
+		return nil, err
 	}
+	if len(listing.Links) == 0 && looksBlocked(html) {
+		return nil, fmt.Errorf("%w: challenge page at %s", ErrBlocked, url)
+	}
+	if len(listing.Links) == 0 && listing.TotalCount > 0 {
+		return nil, fmt.Errorf("%w: %s not found on %s", ErrLayoutChanged, selector, url)
+	}
+	return listing, nil
+}
 
-	product.URL = url
-
-	// Process structured data if available
-	if rawData != nil {
-		if offers, ok := rawData["offers"].(map[string]interface{}); ok {
-			if price, err := strconv.ParseFloat(offers["price"].(string), 64); err == nil {
-				product.Price = models.Price{
-					SellingPrice: price,
-					DiscountedPrice: price, // You might want to adjust this based on your business logic
-				}
-			}
-		}
+func (ps *ProductScraper) scrapeProductPage(tabCtx context.Context, url string, timeout time.Duration) (*models.Product, error) {
+	var html string
+	err := loadPage(tabCtx, url, ps.profiles.For(url).Selectors.ProductDetail, timeout,
+		chromedp.OuterHTML("html", &html),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape product page: %w", err)
 	}
 
-	return &product, nil
-}
\ No newline at end of file
+	page, err := ParseProductPage([]byte(html), url, ps.profiles)
+	if err != nil {
+		return nil, err
+	}
+	return &page.Product, nil
+}
@@ -0,0 +1,146 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+	"trendyol-scraper/config"
+)
+
+// defaultProfile matches the Turkish trendyol.com storefront and fills in
+// any selector a configured profile leaves empty.
+var defaultProfile = config.SelectorProfile{
+	Name: "default",
+	NumberFormat: config.NumberFormat{
+		DecimalSeparator:   ",",
+		ThousandsSeparator: ".",
+		CurrencySymbols:    []string{"TL", "₺"},
+		Currency:           "TRY",
+	},
+	Selectors: config.Selectors{
+		ProductDetail:  ".product-detail",
+		ProductName:    ".pr-new-br span",
+		Brand:          ".merchant-text",
+		Price:          ".prc-dsc",
+		OriginalPrice:  ".prc-org",
+		Rating:         ".rating-line",
+		Images:         ".gallery-modal-content img",
+		Description:    ".detail-attr-container",
+		Variants:       ".variant-selector-item",
+		VariantSoldOut: ".variant-selector-item.so, .variant-selector-item.disabled",
+		ProductLinks:   ".product-card a",
+		ResultCount:    ".dscrptn",
+		Navigation:     "nav",
+		TopCategories:  "nav a",
+		Subcategories:  ".sub-category-header",
+	},
+}
+
+// builtinProfiles are used when the config lists no profiles. They match
+// the profiles in config.yaml, so the fixture corpus parses the same way
+// with or without a config file.
+var builtinProfiles = []config.SelectorProfile{
+	{
+		Name:         "tr",
+		Hosts:        []string{"www.trendyol.com", "trendyol.com"},
+		PathPrefix:   "/",
+		NumberFormat: defaultProfile.NumberFormat,
+	},
+	{
+		Name:       "en-ae",
+		Hosts:      []string{"www.trendyol.com", "trendyol.com"},
+		PathPrefix: "/en/",
+		NumberFormat: config.NumberFormat{
+			DecimalSeparator:   ".",
+			ThousandsSeparator: ",",
+			CurrencySymbols:    []string{"AED"},
+			Currency:           "AED",
+		},
+	},
+}
+
+// Profiles picks the selector profile for a page URL.
+type Profiles struct {
+	profiles []config.SelectorProfile
+}
+
+// NewProfiles uses the profiles of cfg, or the built-in ones when it has
+// none.
+func NewProfiles(cfg *config.Config) *Profiles {
+	configured := cfg.Scraper.Profiles
+	if len(configured) == 0 {
+		configured = builtinProfiles
+	}
+	profiles := make([]config.SelectorProfile, len(configured))
+	for i, p := range configured {
+		profiles[i] = withDefaults(p)
+	}
+	return &Profiles{profiles: profiles}
+}
+
+// For returns the profile whose host matches pageURL with the longest
+// path prefix, or the default profile if none matches. A nil *Profiles
+// always returns the default.
+func (p *Profiles) For(pageURL string) config.SelectorProfile {
+	u, err := url.Parse(pageURL)
+	if p == nil || err != nil {
+		return defaultProfile
+	}
+	host := strings.ToLower(u.Hostname())
+
+	best, bestLen := defaultProfile, -1
+	for _, profile := range p.profiles {
+		if !matchesHost(profile.Hosts, host) || !strings.HasPrefix(u.Path, profile.PathPrefix) {
+			continue
+		}
+		if len(profile.PathPrefix) > bestLen {
+			best, bestLen = profile, len(profile.PathPrefix)
+		}
+	}
+	return best
+}
+
+func matchesHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func withDefaults(p config.SelectorProfile) config.SelectorProfile {
+	d := defaultProfile
+	s := &p.Selectors
+	fill(&s.ProductDetail, d.Selectors.ProductDetail)
+	fill(&s.ProductName, d.Selectors.ProductName)
+	fill(&s.Brand, d.Selectors.Brand)
+	fill(&s.Price, d.Selectors.Price)
+	fill(&s.OriginalPrice, d.Selectors.OriginalPrice)
+	fill(&s.Rating, d.Selectors.Rating)
+	fill(&s.Images, d.Selectors.Images)
+	fill(&s.Description, d.Selectors.Description)
+	fill(&s.Variants, d.Selectors.Variants)
+	fill(&s.VariantSoldOut, d.Selectors.VariantSoldOut)
+	fill(&s.ProductLinks, d.Selectors.ProductLinks)
+	fill(&s.ResultCount, d.Selectors.ResultCount)
+	fill(&s.Navigation, d.Selectors.Navigation)
+	fill(&s.TopCategories, d.Selectors.TopCategories)
+	fill(&s.Subcategories, d.Selectors.Subcategories)
+
+	nf := &p.NumberFormat
+	if nf.DecimalSeparator == "" && nf.ThousandsSeparator == "" {
+		nf.DecimalSeparator = d.NumberFormat.DecimalSeparator
+		nf.ThousandsSeparator = d.NumberFormat.ThousandsSeparator
+	}
+	if len(nf.CurrencySymbols) == 0 {
+		nf.CurrencySymbols = d.NumberFormat.CurrencySymbols
+	}
+	fill(&nf.Currency, d.NumberFormat.Currency)
+	return p
+}
+
+func fill(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
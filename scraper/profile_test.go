@@ -0,0 +1,19 @@
+package scraper
+
+import (
+	"testing"
+	"trendyol-scraper/config"
+)
+
+func TestWithDefaultsFillsNumberFormat(t *testing.T) {
+	p := withDefaults(config.SelectorProfile{Name: "custom"})
+	nf := p.NumberFormat
+	if nf.Currency != "TRY" || nf.DecimalSeparator != "," || nf.ThousandsSeparator != "." || len(nf.CurrencySymbols) == 0 {
+		t.Errorf("number format = %+v, want the default profile's", nf)
+	}
+
+	p = withDefaults(config.SelectorProfile{NumberFormat: config.NumberFormat{Currency: "AED"}})
+	if p.NumberFormat.Currency != "AED" {
+		t.Errorf("currency = %q, want the configured AED", p.NumberFormat.Currency)
+	}
+}
@@ -0,0 +1,249 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"trendyol-scraper/config"
+)
+
+// ErrNoProxy is returned when proxies are configured but none is healthy.
+var ErrNoProxy = errors.New("no healthy proxy")
+
+// Proxy is one entry of the pool. Its state is guarded by the pool.
+type Proxy struct {
+	URL *url.URL
+
+	healthy       bool
+	cooldownUntil time.Time
+}
+
+func (p *Proxy) String() string {
+	return p.URL.Redacted()
+}
+
+// ProxyPool hands out HTTP and SOCKS5 proxies in turn. A proxy that gets
+// blocked rests for the cooldown, and one that cannot be reached is left
+// out until a health check passes again. An empty pool hands out nil, which
+// means a direct connection.
+type ProxyPool struct {
+	proxies       []*Proxy
+	cooldown      time.Duration
+	checkURL      string
+	checkInterval time.Duration
+
+	mu   sync.Mutex
+	next int
+}
+
+func NewProxyPool(cfg *config.Config) (*ProxyPool, error) {
+	pc := cfg.Scraper.Proxies
+	pool := &ProxyPool{
+		cooldown:      time.Duration(pc.CooldownSeconds) * time.Second,
+		checkURL:      pc.HealthCheckURL,
+		checkInterval: time.Duration(pc.HealthCheckIntervalSeconds) * time.Second,
+	}
+	for _, raw := range pc.URLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		pool.proxies = append(pool.proxies, &Proxy{URL: u, healthy: true})
+	}
+	return pool, nil
+}
+
+// Len returns the number of configured proxies. It is safe on a nil pool.
+func (p *ProxyPool) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.proxies)
+}
+
+// Get returns the next healthy proxy, waiting while all of them are
+// cooling down.
+func (p *ProxyPool) Get(ctx context.Context) (*Proxy, error) {
+	if p.Len() == 0 {
+		return nil, nil
+	}
+
+	for {
+		proxy, wait, err := p.pick()
+		if proxy != nil || err != nil {
+			return proxy, err
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// pick returns the next available proxy, or how long until one is.
+func (p *ProxyPool) pick() (*Proxy, time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	anyHealthy := false
+	for i := 0; i < len(p.proxies); i++ {
+		proxy := p.proxies[(p.next+i)%len(p.proxies)]
+		if !proxy.healthy {
+			continue
+		}
+		anyHealthy = true
+		if until := proxy.cooldownUntil.Sub(now); until > 0 {
+			if wait == 0 || until < wait {
+				wait = until
+			}
+			continue
+		}
+		p.next = (p.next + i + 1) % len(p.proxies)
+		return proxy, 0, nil
+	}
+	if !anyHealthy {
+		return nil, 0, ErrNoProxy
+	}
+	return nil, wait, nil
+}
+
+// Cooldown rests proxy after the site blocked a request through it.
+func (p *ProxyPool) Cooldown(proxy *Proxy) {
+	if proxy == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	proxy.cooldownUntil = time.Now().Add(p.cooldown)
+	log.Printf("Proxy %s was blocked, resting for %s", proxy, p.cooldown)
+}
+
+// Fail leaves proxy out until it passes a health check.
+func (p *ProxyPool) Fail(proxy *Proxy) {
+	if proxy == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if proxy.healthy {
+		proxy.healthy = false
+		log.Printf("Proxy %s is unreachable, leaving it out until it passes a health check", proxy)
+	}
+}
+
+// Run checks every proxy at the health check interval until ctx is
+// cancelled. Call Check first to start with a known state.
+func (p *ProxyPool) Run(ctx context.Context) error {
+	if p.Len() == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(p.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		p.Check(ctx)
+	}
+}
+
+// Check requests the health check URL through every proxy in parallel and
+// updates which of them are used.
+func (p *ProxyPool) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	healthy := make([]bool, len(p.proxies))
+	for i, proxy := range p.proxies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			healthy[i] = p.probe(ctx, proxy) == nil
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	up := 0
+	for i, proxy := range p.proxies {
+		proxy.healthy = healthy[i]
+		if healthy[i] {
+			up++
+		}
+	}
+	log.Printf("Proxy health check: %d of %d proxies healthy", up, len(p.proxies))
+}
+
+func (p *ProxyPool) probe(ctx context.Context, proxy *Proxy) error {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxy.URL)},
+	}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, p.checkURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusProxyAuthRequired {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// isProxyError reports whether err came from the proxy rather than the
+// site, in the wording of net/http or Chrome.
+func isProxyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, marker := range []string{"proxyconnect", "socks connect", "ERR_PROXY", "ERR_TUNNEL_CONNECTION_FAILED", "ERR_SOCKS"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+type proxyKey struct{}
+
+// withProxy makes the HTTP client send requests made with ctx through
+// proxy; see proxyFromContext.
+func withProxy(ctx context.Context, proxy *Proxy) context.Context {
+	if proxy == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// proxyFromContext is the Proxy func of the HTTP client's transport.
+func proxyFromContext(req *http.Request) (*url.URL, error) {
+	if proxy, ok := req.Context().Value(proxyKey{}).(*Proxy); ok {
+		return proxy.URL, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
@@ -0,0 +1,212 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/bits"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"trendyol-scraper/config"
+)
+
+// RateLimiter is a token bucket per host shared by every scraper, so the
+// category and product scrapers together never exceed the configured pace.
+// Hosts that answer 429 or 503 are paused, honouring Retry-After, and
+// robots.txt rules are checked before a URL is requested.
+type RateLimiter struct {
+	interval      time.Duration
+	burst         int
+	jitter        time.Duration
+	maxBackoff    time.Duration
+	respectRobots bool
+	robots        *robotsCache
+
+	mu    sync.Mutex
+	hosts map[string]*hostBucket
+}
+
+type hostBucket struct {
+	tokens       float64
+	last         time.Time
+	interval     time.Duration
+	blockedUntil time.Time
+	failures     int
+}
+
+func NewRateLimiter(cfg *config.Config) *RateLimiter {
+	rl := cfg.Scraper.RateLimit
+	burst := rl.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		interval:      time.Duration(cfg.Scraper.DelaySeconds) * time.Second,
+		burst:         burst,
+		jitter:        time.Duration(rl.JitterMillis) * time.Millisecond,
+		maxBackoff:    time.Duration(rl.MaxBackoffSeconds) * time.Second,
+		respectRobots: rl.RespectRobots,
+		robots:        newRobotsCache(cfg),
+		hosts:         make(map[string]*hostBucket),
+	}
+}
+
+// UseProxies sends the robots.txt requests of Wait through proxies, like
+// the page requests they precede. Call it before the limiter is used.
+func (l *RateLimiter) UseProxies(proxies *ProxyPool) {
+	l.robots.proxies = proxies
+}
+
+func (l *RateLimiter) bucket(host string) *hostBucket {
+	b, ok := l.hosts[host]
+	if !ok {
+		b = &hostBucket{tokens: float64(l.burst), last: time.Now(), interval: l.interval}
+		l.hosts[host] = b
+	}
+	return b
+}
+
+// Wait blocks until a request to rawURL may be sent, or ctx is cancelled.
+// It returns ErrDisallowed if robots.txt forbids the URL.
+func (l *RateLimiter) Wait(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	host := u.Host
+
+	if l.respectRobots {
+		rules := l.robots.get(ctx, u)
+		if !rules.allowed(u.RequestURI()) {
+			return fmt.Errorf("%w: %s", ErrDisallowed, rawURL)
+		}
+		if rules.crawlDelay > 0 {
+			l.mu.Lock()
+			if b := l.bucket(host); rules.crawlDelay > b.interval {
+				b.interval = rules.crawlDelay
+			}
+			l.mu.Unlock()
+		}
+	}
+
+	for {
+		l.mu.Lock()
+		b := l.bucket(host)
+		now := time.Now()
+
+		var wait time.Duration
+		if now.Before(b.blockedUntil) {
+			wait = b.blockedUntil.Sub(now)
+		} else {
+			if b.interval > 0 {
+				b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+				if b.tokens > float64(l.burst) {
+					b.tokens = float64(l.burst)
+				}
+			} else {
+				b.tokens = float64(l.burst)
+			}
+			b.last = now
+
+			if b.tokens >= 1 {
+				b.tokens--
+				l.mu.Unlock()
+				return l.sleepJitter(ctx)
+			}
+			wait = time.Duration((1 - b.tokens) * float64(b.interval))
+		}
+		l.mu.Unlock()
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *RateLimiter) sleepJitter(ctx context.Context) error {
+	if l.jitter <= 0 {
+		return nil
+	}
+	return sleepContext(ctx, time.Duration(rand.Int63n(int64(l.jitter))))
+}
+
+// Backoff pauses the host of rawURL after a 429 or 503 response, or a
+// challenge page when status is 0. The pause
+// is taken from Retry-After when present, otherwise it doubles with every
+// consecutive failure up to the configured maximum.
+func (l *RateLimiter) Backoff(rawURL string, status int, retryAfter string) time.Duration {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(u.Host)
+	b.failures++
+
+	pause, ok := parseRetryAfter(retryAfter)
+	if !ok {
+		base := b.interval
+		if base <= 0 {
+			base = time.Second
+		}
+		// Cap the exponent so the shift cannot overflow
+		shift := min(b.failures, 62-bits.Len64(uint64(base)))
+		pause = base << uint(shift)
+	}
+	if l.maxBackoff > 0 && pause > l.maxBackoff {
+		pause = l.maxBackoff
+	}
+
+	b.blockedUntil = time.Now().Add(pause)
+	b.tokens = 0
+	if status > 0 {
+		log.Printf("Host %s answered %d, pausing for %s", u.Host, status, pause)
+	} else {
+		log.Printf("Host %s is blocking requests, pausing for %s", u.Host, pause)
+	}
+	return pause
+}
+
+// Success clears the backoff state of the host of rawURL.
+func (l *RateLimiter) Success(rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bucket(u.Host).failures = 0
+}
+
+// IsThrottled reports whether an HTTP status asks the client to slow down.
+func IsThrottled(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter accepts both delay-seconds and HTTP-date values.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
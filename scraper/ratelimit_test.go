@@ -0,0 +1,120 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"trendyol-scraper/config"
+)
+
+func newTestLimiter(delaySeconds, maxBackoffSeconds int) *RateLimiter {
+	cfg := &config.Config{}
+	cfg.Scraper.DelaySeconds = delaySeconds
+	cfg.Scraper.RateLimit.MaxBackoffSeconds = maxBackoffSeconds
+	return NewRateLimiter(cfg)
+}
+
+func TestBackoff(t *testing.T) {
+	const page = "https://www.trendyol.com/sr?q=shoes"
+	tests := []struct {
+		name       string
+		delay, max int
+		retryAfter []string // one Backoff call per entry
+		want       []time.Duration
+	}{
+		{
+			name:       "doubles per failure",
+			delay:      2,
+			max:        300,
+			retryAfter: []string{"", "", "", ""},
+			want:       []time.Duration{4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second},
+		},
+		{
+			name:       "capped by max_backoff",
+			delay:      2,
+			max:        10,
+			retryAfter: []string{"", "", "", ""},
+			want:       []time.Duration{4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second},
+		},
+		{
+			name:       "one second base without a delay",
+			delay:      0,
+			max:        300,
+			retryAfter: []string{"", ""},
+			want:       []time.Duration{2 * time.Second, 4 * time.Second},
+		},
+		{
+			name:       "Retry-After seconds",
+			delay:      2,
+			max:        300,
+			retryAfter: []string{"7", " 0 ", "1000"},
+			want:       []time.Duration{7 * time.Second, 0, 300 * time.Second},
+		},
+		{
+			name:       "past Retry-After date",
+			delay:      2,
+			max:        300,
+			retryAfter: []string{"Wed, 21 Oct 2015 07:28:00 GMT"},
+			want:       []time.Duration{0},
+		},
+		{
+			name:       "invalid Retry-After falls back to doubling",
+			delay:      2,
+			max:        300,
+			retryAfter: []string{"soon"},
+			want:       []time.Duration{4 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(tt.delay, tt.max)
+			for i, ra := range tt.retryAfter {
+				if got := l.Backoff(page, 429, ra); got != tt.want[i] {
+					t.Errorf("Backoff #%d (Retry-After %q) = %s, want %s", i+1, ra, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBackoffDoesNotOverflow(t *testing.T) {
+	l := newTestLimiter(2, 0) // no max_backoff
+	var last time.Duration
+	for i := 1; i <= 100; i++ {
+		pause := l.Backoff("https://www.trendyol.com/", 503, "")
+		if pause <= 0 || pause < last {
+			t.Fatalf("Backoff #%d = %s after %s, want a growing positive pause", i, pause, last)
+		}
+		last = pause
+	}
+}
+
+func TestBackoffPerHostAndSuccess(t *testing.T) {
+	l := newTestLimiter(2, 300)
+	l.Backoff("https://www.trendyol.com/a", 429, "")
+	l.Backoff("https://www.trendyol.com/b", 429, "")
+
+	if got := l.Backoff("https://cdn.dsmcdn.com/x.jpg", 429, ""); got != 4*time.Second {
+		t.Errorf("other host = %s, want its own first pause of 4s", got)
+	}
+
+	l.Success("https://www.trendyol.com/c")
+	if got := l.Backoff("https://www.trendyol.com/d", 429, ""); got != 4*time.Second {
+		t.Errorf("after Success = %s, want the first pause of 4s again", got)
+	}
+}
+
+func TestWaitHonoursBackoff(t *testing.T) {
+	l := newTestLimiter(0, 300)
+	l.Backoff("https://www.trendyol.com/", 429, "5")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "https://www.trendyol.com/sr"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait on a paused host = %v, want it to block until the deadline", err)
+	}
+	if err := l.Wait(context.Background(), "https://other.example.com/"); err != nil {
+		t.Errorf("Wait on another host = %v, want nil", err)
+	}
+}
@@ -0,0 +1,126 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"trendyol-scraper/config"
+	"trendyol-scraper/frontier"
+)
+
+// RetryPolicy retries failed page loads with exponential backoff, with
+// attempts and delays chosen by the class of the error. Like the rate
+// limiter it is shared by every scraper of the process, and it counts the
+// errors of the run for the summary.
+type RetryPolicy struct {
+	rules    map[ErrorClass]config.RetryRule
+	maxDelay time.Duration
+	limiter  *RateLimiter
+
+	mu      sync.Mutex
+	pages   int
+	retries int
+	errors  map[ErrorClass]int
+}
+
+func NewRetryPolicy(cfg *config.Config, limiter *RateLimiter) *RetryPolicy {
+	r := cfg.Scraper.Retry
+	return &RetryPolicy{
+		rules: map[ErrorClass]config.RetryRule{
+			ClassNotFound:      r.NotFound,
+			ClassBlocked:       r.Blocked,
+			ClassLayoutChanged: r.LayoutChanged,
+			ClassTimeout:       r.Timeout,
+			ClassOther:         r.Other,
+		},
+		maxDelay: time.Duration(r.MaxDelaySeconds) * time.Second,
+		limiter:  limiter,
+		errors:   make(map[ErrorClass]int),
+	}
+}
+
+// retryable reports whether another attempt at a page could succeed.
+// Errors that cannot are marked permanent for the frontier.
+func (p *RetryPolicy) retryable(err error) bool {
+	return !errors.Is(err, ErrDisallowed) && p.rules[Classify(err)].Attempts > 1
+}
+
+// delay is the wait before attempt+1 of a page that failed with class.
+func (p *RetryPolicy) delay(class ErrorClass, attempt int) time.Duration {
+	d := time.Duration(p.rules[class].BaseDelayMillis) * time.Millisecond << (attempt - 1)
+	if d > p.maxDelay {
+		d = p.maxDelay
+	}
+	return d
+}
+
+func (p *RetryPolicy) record(err error, retried bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		p.pages++
+		return
+	}
+	p.errors[Classify(err)]++
+	if retried {
+		p.retries++
+	}
+}
+
+// Summary describes the pages loaded and errors seen so far, for the log
+// line at the end of a run.
+func (p *RetryPolicy) Summary() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	counts := make([]string, len(errorClasses))
+	for i, class := range errorClasses {
+		counts[i] = fmt.Sprintf("%s=%d", class, p.errors[class])
+	}
+	return fmt.Sprintf("%d pages loaded, %d retries; errors: %s", p.pages, p.retries, strings.Join(counts, " "))
+}
+
+// withRetry calls fetch until it succeeds or the rule for its error class
+// runs out of attempts. A blocked host is also paused in the rate limiter,
+// so the other workers back off with it. The last error is returned, marked
+// permanent when no later attempt could succeed either.
+func withRetry[T any](ctx context.Context, p *RetryPolicy, url string, fetch func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := fetch()
+		if err == nil || ctx.Err() != nil {
+			if err == nil {
+				p.record(nil, false)
+			}
+			return result, err
+		}
+
+		class := Classify(err)
+		retry := p.retryable(err) && attempt < p.rules[class].Attempts
+		p.record(err, retry)
+		if !retry {
+			if !p.retryable(err) {
+				err = frontier.Permanent(err)
+			}
+			return result, err
+		}
+
+		if class == ClassBlocked {
+			var throttled *throttledError
+			if errors.As(err, &throttled) {
+				p.limiter.Backoff(url, throttled.status, throttled.retryAfter)
+			} else {
+				p.limiter.Backoff(url, 0, "")
+			}
+		}
+		delay := p.delay(class, attempt)
+		log.Printf("Failed to load %s (%s): %v; retrying in %s", url, class, err, delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return result, err
+		}
+	}
+}
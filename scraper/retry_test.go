@@ -0,0 +1,209 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+	"trendyol-scraper/config"
+	"trendyol-scraper/frontier"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{fmt.Errorf("%w: /p-1", ErrNotFound), ClassNotFound},
+		{fmt.Errorf("load: %w", fmt.Errorf("%w: captcha", ErrBlocked)), ClassBlocked},
+		{fmt.Errorf("%w: /checkout", ErrDisallowed), ClassBlocked},
+		{fmt.Errorf("%w: no price", ErrLayoutChanged), ClassLayoutChanged},
+		{ErrTimeout, ClassTimeout},
+		{fmt.Errorf("navigate: %w", context.DeadlineExceeded), ClassTimeout},
+		{&url.Error{Op: "Get", URL: "https://www.trendyol.com", Err: timeoutError{}}, ClassTimeout},
+		{context.Canceled, ClassOther},
+		{errors.New("connection reset"), ClassOther},
+		{frontier.Permanent(ErrNotFound), ClassNotFound},
+		{&throttledError{status: http.StatusTooManyRequests}, ClassBlocked},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusOK, nil},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusGone, ErrNotFound},
+		{http.StatusForbidden, ErrBlocked},
+		{http.StatusTooManyRequests, ErrBlocked},
+		{http.StatusServiceUnavailable, ErrBlocked},
+		{http.StatusInternalServerError, nil},
+	}
+	for _, tt := range tests {
+		if got := statusError(tt.status); got != tt.want {
+			t.Errorf("statusError(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func newTestRetryPolicy(limiter *RateLimiter) *RetryPolicy {
+	cfg := &config.Config{}
+	cfg.Scraper.Retry.MaxDelaySeconds = 1
+	cfg.Scraper.Retry.NotFound = config.RetryRule{Attempts: 1}
+	cfg.Scraper.Retry.Blocked = config.RetryRule{Attempts: 2}
+	cfg.Scraper.Retry.LayoutChanged = config.RetryRule{Attempts: 1}
+	cfg.Scraper.Retry.Timeout = config.RetryRule{Attempts: 3}
+	cfg.Scraper.Retry.Other = config.RetryRule{Attempts: 2}
+	return NewRetryPolicy(cfg, limiter)
+}
+
+// isPermanent reports whether a frontier gives up on err at once.
+func isPermanent(t *testing.T, err error) bool {
+	ctx := context.Background()
+	f := frontier.NewMemory(10)
+	if err := f.Fail(ctx, "s", "u", err); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := f.Entries(ctx, "s")
+	return entries[0].Status == frontier.Failed
+}
+
+func TestWithRetry(t *testing.T) {
+	const page = "https://www.trendyol.com/p-1"
+	tests := []struct {
+		name          string
+		errs          []error // returned by successive calls; nil succeeds
+		wantCalls     int
+		wantErr       error
+		wantPermanent bool
+		wantSummary   string
+	}{
+		{
+			name:        "first try",
+			errs:        []error{nil},
+			wantCalls:   1,
+			wantSummary: "1 pages loaded, 0 retries; errors: not_found=0 blocked=0 layout_changed=0 timeout=0 other=0",
+		},
+		{
+			name:        "succeeds on the last attempt",
+			errs:        []error{ErrTimeout, ErrTimeout, nil},
+			wantCalls:   3,
+			wantSummary: "1 pages loaded, 2 retries; errors: not_found=0 blocked=0 layout_changed=0 timeout=2 other=0",
+		},
+		{
+			name:        "out of attempts",
+			errs:        []error{ErrTimeout, ErrTimeout, ErrTimeout, nil},
+			wantCalls:   3,
+			wantErr:     ErrTimeout,
+			wantSummary: "0 pages loaded, 2 retries; errors: not_found=0 blocked=0 layout_changed=0 timeout=3 other=0",
+		},
+		{
+			name:          "single attempt class is permanent",
+			errs:          []error{ErrNotFound, nil},
+			wantCalls:     1,
+			wantErr:       ErrNotFound,
+			wantPermanent: true,
+			wantSummary:   "0 pages loaded, 0 retries; errors: not_found=1 blocked=0 layout_changed=0 timeout=0 other=0",
+		},
+		{
+			name:          "disallowed is never retried",
+			errs:          []error{ErrDisallowed, nil},
+			wantCalls:     1,
+			wantErr:       ErrDisallowed,
+			wantPermanent: true,
+			wantSummary:   "0 pages loaded, 0 retries; errors: not_found=0 blocked=1 layout_changed=0 timeout=0 other=0",
+		},
+		{
+			// Attempts count across classes, so the timeout used up one of
+			// the two blocked attempts
+			name:        "class changes between attempts",
+			errs:        []error{ErrTimeout, ErrBlocked, nil},
+			wantCalls:   2,
+			wantErr:     ErrBlocked,
+			wantSummary: "0 pages loaded, 1 retries; errors: not_found=0 blocked=1 layout_changed=0 timeout=1 other=0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestRetryPolicy(newTestLimiter(1, 1))
+			calls := 0
+			_, err := withRetry(context.Background(), p, page, func() (int, error) {
+				err := tt.errs[calls]
+				calls++
+				return calls, err
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("fetch called %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil && err != nil || !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && isPermanent(t, err) != tt.wantPermanent {
+				t.Errorf("permanent = %v, want %v", !tt.wantPermanent, tt.wantPermanent)
+			}
+			if got := p.Summary(); got != tt.wantSummary {
+				t.Errorf("Summary() = %q, want %q", got, tt.wantSummary)
+			}
+		})
+	}
+}
+
+func TestWithRetryPausesBlockedHost(t *testing.T) {
+	limiter := newTestLimiter(1, 1)
+	p := newTestRetryPolicy(limiter)
+	withRetry(context.Background(), p, "https://www.trendyol.com/p-1", func() (int, error) {
+		return 0, ErrBlocked
+	})
+	if failures := limiter.hosts["www.trendyol.com"].failures; failures != 1 {
+		t.Errorf("host failures = %d, want one Backoff before the retry", failures)
+	}
+}
+
+func TestWithRetryHonoursRetryAfter(t *testing.T) {
+	limiter := newTestLimiter(1, 300)
+	p := newTestRetryPolicy(limiter)
+	withRetry(context.Background(), p, "https://www.trendyol.com/p-1", func() (int, error) {
+		return 0, &throttledError{status: http.StatusTooManyRequests, retryAfter: "120"}
+	})
+	pause := time.Until(limiter.hosts["www.trendyol.com"].blockedUntil)
+	if pause < 110*time.Second || pause > 120*time.Second {
+		t.Errorf("host paused for %s, want the 120s of Retry-After", pause)
+	}
+}
+
+func TestWithRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newTestRetryPolicy(newTestLimiter(1, 1))
+	calls := 0
+	_, err := withRetry(ctx, p, "https://www.trendyol.com/p-1", func() (int, error) {
+		calls++
+		cancel()
+		return 0, ErrTimeout
+	})
+	if calls != 1 || !errors.Is(err, ErrTimeout) {
+		t.Errorf("calls = %d, err = %v, want one call returning its error", calls, err)
+	}
+	if isPermanent(t, err) {
+		t.Error("an error cut short by cancellation must not be permanent")
+	}
+}
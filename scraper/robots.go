@@ -0,0 +1,246 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"trendyol-scraper/config"
+)
+
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// robotsRules are the Allow/Disallow rules that apply to our user agent.
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+}
+
+// allowed applies the longest matching rule to path, which includes the
+// query; Allow wins ties.
+func (r *robotsRules) allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	best, allow := -1, true
+	for _, rule := range r.disallow {
+		if len(rule) > best && ruleMatches(rule, path) {
+			best, allow = len(rule), false
+		}
+	}
+	for _, rule := range r.allow {
+		if len(rule) >= best && ruleMatches(rule, path) {
+			best, allow = len(rule), true
+		}
+	}
+	return allow
+}
+
+// ruleMatches matches a robots.txt path pattern against the start of path.
+// '*' matches any sequence of characters and a trailing '$' makes the
+// pattern match only the whole path (RFC 9309).
+func ruleMatches(rule, path string) bool {
+	if strings.HasSuffix(rule, "$") {
+		rule = rule[:len(rule)-1]
+	} else {
+		rule += "*"
+	}
+
+	// Glob matching that backtracks to the last '*'
+	r, p := 0, 0
+	star, mark := -1, 0
+	for p < len(path) {
+		switch {
+		case r < len(rule) && rule[r] == '*':
+			star, mark = r, p
+			r++
+		case r < len(rule) && rule[r] == path[p]:
+			r++
+			p++
+		case star >= 0:
+			mark++
+			r, p = star+1, mark
+		default:
+			return false
+		}
+	}
+	for r < len(rule) && rule[r] == '*' {
+		r++
+	}
+	return r == len(rule)
+}
+
+// robotsCache requests robots.txt like a page: through the proxy pool, if
+// there is one, with the next identity. The rules are those for
+// scraper.user_agent.
+type robotsCache struct {
+	userAgent  string
+	httpClient *http.Client
+	identities *Identities
+	proxies    *ProxyPool // set by RateLimiter.UseProxies
+
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+// robotsEntry holds the rules of one host. Its own lock makes concurrent
+// requests to the host wait for a single fetch without holding up other
+// hosts.
+type robotsEntry struct {
+	mu    sync.Mutex
+	rules *robotsRules
+}
+
+func newRobotsCache(cfg *config.Config) *robotsCache {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFromContext
+	return &robotsCache{
+		userAgent:  cfg.Scraper.UserAgent,
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		identities: NewIdentities(cfg),
+		hosts:      make(map[string]*robotsEntry),
+	}
+}
+
+// get returns the rules for the host of u, fetching robots.txt once per
+// host. A missing or unreadable robots.txt allows everything; a fetch cut
+// short by ctx or failed by the proxy is not remembered, so the next
+// request tries again.
+func (c *robotsCache) get(ctx context.Context, u *url.URL) *robotsRules {
+	key := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	entry, ok := c.hosts[key]
+	if !ok {
+		entry = &robotsEntry{}
+		c.hosts[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.rules != nil {
+		return entry.rules
+	}
+	rules, ok := c.fetch(ctx, key)
+	if ok {
+		entry.rules = rules
+	}
+	return rules
+}
+
+// fetch returns the rules of origin, and whether they are worth
+// remembering.
+func (c *robotsCache) fetch(ctx context.Context, origin string) (*robotsRules, bool) {
+	proxy, err := c.proxies.Get(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Failed to fetch robots.txt for %s: %v", origin, err)
+		}
+		return &robotsRules{}, false
+	}
+	req, err := http.NewRequestWithContext(withProxy(ctx, proxy), http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return &robotsRules{}, true
+	}
+	apply(c.identities.Next(), req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return &robotsRules{}, false
+		}
+		log.Printf("Failed to fetch robots.txt for %s: %v", origin, err)
+		if isProxyError(err) {
+			c.proxies.Fail(proxy)
+			return &robotsRules{}, false
+		}
+		return &robotsRules{}, true
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &robotsRules{}, true
+	}
+	return parseRobots(resp.Body, c.userAgent), true
+}
+
+// parseRobots returns the group for the most specific user agent that
+// matches ours, falling back to the "*" group.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	ua := strings.ToLower(userAgent)
+	groups := map[string]*robotsRules{}
+	var current []string
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group
+			if inRules {
+				current = nil
+				inRules = false
+			}
+			agent := strings.ToLower(value)
+			current = append(current, agent)
+			if groups[agent] == nil {
+				groups[agent] = &robotsRules{}
+			}
+		case "allow", "disallow", "crawl-delay":
+			inRules = true
+			for _, agent := range current {
+				g := groups[agent]
+				switch key {
+				case "allow":
+					if value != "" {
+						g.allow = append(g.allow, value)
+					}
+				case "disallow":
+					if value != "" {
+						g.disallow = append(g.disallow, value)
+					}
+				case "crawl-delay":
+					if secs, err := strconv.ParseFloat(value, 64); err == nil {
+						g.crawlDelay = time.Duration(secs * float64(time.Second))
+					}
+				}
+			}
+		}
+	}
+
+	var best *robotsRules
+	bestLen := 0
+	for agent, rules := range groups {
+		if agent != "*" && strings.Contains(ua, agent) && len(agent) > bestLen {
+			best, bestLen = rules, len(agent)
+		}
+	}
+	if best != nil {
+		return best
+	}
+	if rules, ok := groups["*"]; ok {
+		return rules
+	}
+	return &robotsRules{}
+}
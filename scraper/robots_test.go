@@ -0,0 +1,198 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"trendyol-scraper/config"
+)
+
+const testRobots = `# comments and blank lines are ignored
+
+User-agent: *
+Disallow: /private
+Allow: /private/open
+Disallow: /*?sort=
+Disallow: /*.pdf$
+Crawl-delay: 1.5
+
+User-agent: Googlebot
+User-agent: TrendBot
+Disallow: /bots-only   # trailing comment
+Disallow:
+
+User-agent: TrendBot/2
+Disallow: /v2
+`
+
+func TestParseRobotsGroups(t *testing.T) {
+	tests := []struct {
+		userAgent    string
+		wantDisallow []string
+		wantDelay    time.Duration
+	}{
+		{"Mozilla/5.0", []string{"/private", "/*?sort=", "/*.pdf$"}, 1500 * time.Millisecond},
+		{"", []string{"/private", "/*?sort=", "/*.pdf$"}, 1500 * time.Millisecond},
+		{"Mozilla/5.0 (compatible; trendbot/1.0)", []string{"/bots-only"}, 0},
+		{"TrendBot/2.1", []string{"/v2"}, 0}, // the most specific agent wins
+		{"googlebot", []string{"/bots-only"}, 0},
+	}
+	for _, tt := range tests {
+		rules := parseRobots(strings.NewReader(testRobots), tt.userAgent)
+		if strings.Join(rules.disallow, " ") != strings.Join(tt.wantDisallow, " ") || rules.crawlDelay != tt.wantDelay {
+			t.Errorf("parseRobots(%q) = disallow %q delay %s, want %q %s", tt.userAgent, rules.disallow, rules.crawlDelay, tt.wantDisallow, tt.wantDelay)
+		}
+	}
+
+	if rules := parseRobots(strings.NewReader("User-agent: other\nDisallow: /\n"), "Mozilla"); !rules.allowed("/anything") {
+		t.Error("a robots.txt without a matching or * group must allow everything")
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(testRobots), "Mozilla/5.0")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"", true},
+		{"/", true},
+		{"/sr?q=shoes", true},
+		{"/private", false},
+		{"/private/account", false},
+		{"/privateer", false},
+		{"/private/open", true}, // the longer Allow wins
+		{"/private/open/x", true},
+		{"/sr?q=shoes&sort=price", true}, // only ?sort= is disallowed
+		{"/sr?sort=price", false},
+		{"/catalog.pdf", false},
+		{"/catalog.pdf?v=2", true}, // $ anchors the end of the path
+		{"/docs/a.pdf", false},
+		{"/catalog.pdfx", true},
+	}
+	for _, tt := range tests {
+		if got := rules.allowed(tt.path); got != tt.want {
+			t.Errorf("allowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		rule, path string
+		want       bool
+	}{
+		{"/", "/anything", true},
+		{"/a", "/abc", true},
+		{"/a$", "/a", true},
+		{"/a$", "/ab", false},
+		{"/*/reviews", "/apple/reviews", true},
+		{"/*/reviews", "/apple/x/reviews/1", true},
+		{"/*/reviews", "/reviews", false},
+		{"*", "/", true},
+		{"/a*b*c$", "/axxbyyc", true},
+		{"/a*b*c$", "/axxbyycd", false},
+		{"/a**b", "/ab", true},
+		{"/*.json$", "/x.json.bak", false},
+		{"/x", "/X", false}, // paths are case sensitive
+	}
+	for _, tt := range tests {
+		if got := ruleMatches(tt.rule, tt.path); got != tt.want {
+			t.Errorf("ruleMatches(%q, %q) = %v, want %v", tt.rule, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsCache(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{}
+	cfg.Scraper.UserAgent = "TestBot"
+	cache := newRobotsCache(cfg)
+	u, _ := url.Parse(srv.URL + "/private/x")
+
+	// A fetch cut short by the context is not remembered
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if rules := cache.get(cancelled, u); !rules.allowed("/private/x") {
+		t.Error("a failed fetch must allow everything")
+	}
+
+	for i := 0; i < 3; i++ {
+		if cache.get(context.Background(), u).allowed("/private/x") {
+			t.Fatal("/private/x allowed, want the rules of the robots.txt")
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want once", n)
+	}
+}
+
+func TestRobotsThroughProxy(t *testing.T) {
+	// A plain HTTP proxy sees the absolute URL of every request
+	var requests atomic.Int32
+	var userAgent atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Host != "shop.example" || r.URL.Path != "/robots.txt" {
+			http.Error(w, "unexpected "+r.URL.String(), http.StatusBadGateway)
+			return
+		}
+		userAgent.Store(r.Header.Get("User-Agent"))
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+	defer proxy.Close()
+
+	cfg := &config.Config{}
+	cfg.Scraper.UserAgent = "TestBot"
+	cfg.Scraper.Identities = []config.Identity{{UserAgent: "Identity/1.0"}}
+	cfg.Scraper.Proxies.URLs = []string{proxy.URL}
+	pool, err := NewProxyPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := NewRateLimiter(cfg)
+	limiter.UseProxies(pool)
+
+	u, _ := url.Parse("http://shop.example/private/x")
+	if limiter.robots.get(context.Background(), u).allowed("/private/x") {
+		t.Error("/private/x allowed, want the rules served through the proxy")
+	}
+	if requests.Load() != 1 || userAgent.Load() != "Identity/1.0" {
+		t.Errorf("proxy saw %d requests with user agent %v, want one with the identity's", requests.Load(), userAgent.Load())
+	}
+}
+
+func TestRobotsProxyFailureNotRemembered(t *testing.T) {
+	cfg := &config.Config{}
+	// Nothing listens on port 1
+	cfg.Scraper.Proxies.URLs = []string{"http://127.0.0.1:1"}
+	pool, err := NewProxyPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := newRobotsCache(cfg)
+	cache.proxies = pool
+
+	u, _ := url.Parse("http://shop.example/x")
+	if !cache.get(context.Background(), u).allowed("/x") {
+		t.Error("a failed fetch must allow everything")
+	}
+	if cache.hosts["http://shop.example"].rules != nil {
+		t.Error("rules remembered after the proxy failed, want the next request to try again")
+	}
+}
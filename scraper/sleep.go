@@ -0,0 +1,19 @@
+package scraper
+
+import (
+	"context"
+	"time"
+)
+
+// sleepContext waits for d or until ctx is cancelled, whichever is first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
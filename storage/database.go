@@ -1,9 +1,14 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 	"trendyol-scraper/models"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // StorageHandler defines the interface for storage operations
@@ -11,43 +16,110 @@ type StorageHandler interface {
 	SaveCategories(categories []models.Category) error
 	SaveProducts(products []models.Product) error
 	SaveVariants(variants []models.Variant) error
-	SaveImages(images []string) error
+	SaveImages(images []models.Image) error
 	GetProduct(id int) (*models.Product, error)
+	// GetPriceHistory returns a product's price observations between from
+	// and to, oldest first; a zero time leaves that end open.
+	GetPriceHistory(productID int, from, to time.Time) ([]models.PriceHistory, error)
+	ListProducts(filter ProductFilter, page Page) (*ProductList, error)
+	// SearchProducts returns up to 100 products whose name or brand
+	// contains every word of query, ignoring case.
+	SearchProducts(query string) ([]models.Product, error)
+	GetCategoryTree() ([]models.Category, error)
 }
 
+// ErrNotFound is returned by GetProduct for an unknown product.
+var ErrNotFound = errors.New("not found")
+
 type DatabaseStorage struct {
 	db *gorm.DB
 }
 
+// NewDatabaseStorage expects the schema to be managed by the migrations
+// package; it no longer creates tables itself.
 func NewDatabaseStorage(db *gorm.DB) (*DatabaseStorage, error) {
-
-	// Auto migrate models
-	if err := db.AutoMigrate(&models.Category{}, &models.Product{}, &models.Variant{}); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	if db == nil {
+		return nil, fmt.Errorf("database connection is required")
 	}
 
 	return &DatabaseStorage{db: db}, nil
 }
 
+// SaveCategories saves a category tree in one transaction, parents before
+// their children, and then recounts the products of every category.
 func (ds *DatabaseStorage) SaveCategories(categories []models.Category) error {
 	return ds.db.Transaction(func(tx *gorm.DB) error {
-		for _, cat := range categories {
-			if err := tx.Save(&cat).Error; err != nil {
-				return err
-			}
-			if len(cat.Children) > 0 {
-				if err := ds.SaveCategories(cat.Children); err != nil {
-					return err
-				}
-			}
+		if err := saveCategoryTree(tx, categories); err != nil {
+			return err
 		}
-		return nil
+		return RefreshProductCounts(tx)
 	})
 }
 
+func saveCategoryTree(tx *gorm.DB, categories []models.Category) error {
+	for _, cat := range categories {
+		if cat.ID == "" {
+			return fmt.Errorf("category %q (%s) has no ID", cat.Name, cat.URL)
+		}
+		if err := tx.Omit(clause.Associations).Save(&cat).Error; err != nil {
+			return err
+		}
+		if err := saveCategoryTree(tx, cat.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshProductCounts sets the product count of every category to the
+// number of stored products in it and its subcategories.
+func RefreshProductCounts(db *gorm.DB) error {
+	return refreshProductCounts(db, `SELECT id FROM categories`)
+}
+
+// RefreshProductCountsFor recounts only the given categories and their
+// ancestors, the ones whose counts change when products are added to or
+// moved out of them.
+func RefreshProductCountsFor(db *gorm.DB, categoryIDs []string) error {
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+	return refreshProductCounts(db, `
+	SELECT id, parent_id FROM categories WHERE id IN ?
+	UNION
+	SELECT c.id, c.parent_id FROM categories c JOIN roots ON c.id = roots.parent_id`, categoryIDs)
+}
+
+// refreshProductCounts recounts the categories selected by roots, a
+// possibly recursive query whose first column is the category ID.
+func refreshProductCounts(db *gorm.DB, roots string, args ...interface{}) error {
+	return db.Exec(`
+WITH RECURSIVE roots AS (`+roots+`
+),
+tree AS (
+	SELECT roots.id AS root, roots.id FROM roots
+	UNION
+	SELECT tree.root, c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+)
+UPDATE categories SET product_count = counts.n
+FROM (
+	SELECT tree.root, COUNT(p.id) AS n
+	FROM tree LEFT JOIN products p ON p.category_id = tree.id
+	GROUP BY tree.root
+) counts
+WHERE categories.id = counts.root`, args...).Error
+}
+
 func (s *DatabaseStorage) GetProduct(id int) (*models.Product, error) {
 	var product models.Product
-	if err := s.db.First(&product, id).Error; err != nil {
+	if err := s.db.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).First(&product, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("product %d: %w", id, ErrNotFound)
+		}
 		return nil, err
 	}
 	return &product, nil
@@ -56,7 +128,8 @@ func (s *DatabaseStorage) GetProduct(id int) (*models.Product, error) {
 func (ds *DatabaseStorage) SaveProducts(products []models.Product) error {
 	return ds.db.Transaction(func(tx *gorm.DB) error {
 		for _, p := range products {
-			if err := tx.Save(&p).Error; err != nil {
+			// Variants are saved separately, see SaveVariants
+			if err := tx.Omit(clause.Associations).Save(&p).Error; err != nil {
 				return err
 			}
 		}
@@ -64,24 +137,150 @@ func (ds *DatabaseStorage) SaveProducts(products []models.Product) error {
 	})
 }
 
+// SaveVariants replaces the variants of every product that has one in
+// variants, so options the site no longer offers are dropped. The products
+// must already be saved.
 func (ds *DatabaseStorage) SaveVariants(variants []models.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	// One row per SKU, as a batch may not update the same row twice
+	seen := make(map[int]bool)
+	bySKU := make(map[string]int)
+	var productIDs []int
+	var rows []models.Variant
+	for _, v := range variants {
+		if !seen[v.ProductID] {
+			seen[v.ProductID] = true
+			productIDs = append(productIDs, v.ProductID)
+		}
+		if i, ok := bySKU[v.SKU]; ok {
+			rows[i] = v
+			continue
+		}
+		bySKU[v.SKU] = len(rows)
+		rows = append(rows, v)
+	}
+
 	return ds.db.Transaction(func(tx *gorm.DB) error {
-		for _, v := range variants {
-			if err := tx.Save(&v).Error; err != nil {
-				return err
-			}
+		if err := tx.Where("product_id IN ?", productIDs).Delete(&models.Variant{}).Error; err != nil {
+			return err
 		}
-		return nil
+		// A SKU that moved to another product follows it
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "sku"}},
+			UpdateAll: true,
+		}).Create(&rows).Error
 	})
 }
 
-func (ds *DatabaseStorage) SaveImages(images []string) error {
+// SaveImages replaces the gallery of every product that has an image in
+// images, like SaveVariants. An image URL is stored once; when several
+// products use it, it stays with the last one saved.
+func (ds *DatabaseStorage) SaveImages(images []models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	byURL := make(map[string]int)
+	var productIDs []int
+	var rows []models.Image
+	for _, img := range images {
+		if !seen[img.ProductID] {
+			seen[img.ProductID] = true
+			productIDs = append(productIDs, img.ProductID)
+		}
+		if i, ok := byURL[img.URL]; ok {
+			rows[i] = img
+			continue
+		}
+		byURL[img.URL] = len(rows)
+		rows = append(rows, img)
+	}
+
 	return ds.db.Transaction(func(tx *gorm.DB) error {
-		for _, img := range images {
-			if err := tx.Create(&models.Image{URL: img}).Error; err != nil {
-				return err
-			}
+		if err := tx.Where("product_id IN ?", productIDs).Delete(&models.Image{}).Error; err != nil {
+			return err
 		}
-		return nil
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "url"}},
+			UpdateAll: true,
+		}).Create(&rows).Error
 	})
 }
+
+func (ds *DatabaseStorage) GetPriceHistory(productID int, from, to time.Time) ([]models.PriceHistory, error) {
+	return PriceRange(ds.db, productID, from, to, false)
+}
+
+func (ds *DatabaseStorage) ListProducts(filter ProductFilter, page Page) (*ProductList, error) {
+	page = page.normalize()
+	query := ds.db.Model(&models.Product{})
+	if filter.CategoryID != "" {
+		query = query.Where(`category_id IN (
+	WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE id = ?
+		UNION
+		SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+	)
+	SELECT id FROM tree UNION SELECT ?)`, filter.CategoryID, filter.CategoryID)
+	}
+	if filter.BrandID != 0 {
+		query = query.Where("brand_id = ?", filter.BrandID)
+	}
+	if !filter.MinPrice.IsZero() {
+		query = query.Where("discounted_price >= ?", filter.MinPrice)
+	}
+	if !filter.MaxPrice.IsZero() {
+		query = query.Where("discounted_price <= ?", filter.MaxPrice)
+	}
+	if filter.OnFlashSale {
+		query = query.Where("on_flash_sale")
+	}
+	if filter.HasCollectableCoupon {
+		query = query.Where("has_collectable_coupon")
+	}
+
+	// A new session so Count and Find do not share their statement
+	query = query.Session(&gorm.Session{})
+	list := &ProductList{Page: page}
+	if err := query.Count(&list.Total).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Order(listOrder).Offset(page.offset()).Limit(page.Size).Find(&list.Products).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (ds *DatabaseStorage) SearchProducts(query string) ([]models.Product, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	db := ds.db
+	for _, term := range terms {
+		pattern := "%" + likeEscaper.Replace(term) + "%"
+		db = db.Where("(name ILIKE ? OR brand ILIKE ?)", pattern, pattern)
+	}
+	var products []models.Product
+	if err := db.Order(searchOrder).Limit(searchLimit).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// likeEscaper makes a search term match literally in a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// GetCategoryTree returns the stored categories as trees in
+// categoryOrder.
+func (ds *DatabaseStorage) GetCategoryTree() ([]models.Category, error) {
+	var categories []models.Category
+	if err := ds.db.Order(categoryOrder).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return buildCategoryTree(categories), nil
+}
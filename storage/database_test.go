@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"trendyol-scraper/internal/dbtest"
+	"trendyol-scraper/models"
+)
+
+func TestDatabaseStorageReadAPI(t *testing.T) {
+	ds, err := NewDatabaseStorage(dbtest.Open(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testReadAPI(t, ds)
+}
+
+func TestDatabaseStoragePriceHistory(t *testing.T) {
+	db := dbtest.Open(t)
+	ds, err := NewDatabaseStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.SaveProducts([]models.Product{testProduct(1, "Kılıf", "Choice", 1, "", 1000)}); err != nil {
+		t.Fatal(err)
+	}
+	for day, price := range []int64{1000, 900, 900} {
+		observation := models.ObservePrice(testProduct(1, "Kılıf", "Choice", 1, "", price), day != 2)
+		observation.RecordedAt = time.Date(2025, 5, day+1, 12, 0, 0, 0, time.UTC)
+		if err := db.Create(&observation).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := ds.GetPriceHistory(1, time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].RecordedAt.Day() != 2 || history[1].RecordedAt.Day() != 3 {
+		t.Fatalf("history = %+v, want the observations of May 2 and 3", history)
+	}
+	if want := (models.Money{Amount: 900, Currency: "TRY"}); history[0].DiscountedPrice != want {
+		t.Errorf("discounted price = %v, want %v", history[0].DiscountedPrice, want)
+	}
+}
+
+func TestRefreshProductCountsFor(t *testing.T) {
+	db := dbtest.Open(t)
+	ds, err := NewDatabaseStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.SaveCategories(testCategories()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.SaveProducts(testProducts()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&models.Category{}).Where("true").Update("product_count", 99).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RefreshProductCountsFor(db, []string{"kadin-elbise"}); err != nil {
+		t.Fatal(err)
+	}
+	var categories []models.Category
+	if err := db.Order("id").Find(&categories).Error; err != nil {
+		t.Fatal(err)
+	}
+	// The category and its parent are recounted, the others left alone
+	want := map[string]int{"erkek": 99, "kadin": 3, "kadin-ayakkabi": 99, "kadin-elbise": 2}
+	for _, c := range categories {
+		if c.ProductCount != want[c.ID] {
+			t.Errorf("%s counts %d products, want %d", c.ID, c.ProductCount, want[c.ID])
+		}
+	}
+}
+
+// The statements below are only built, so these run without a database.
+
+func TestListProductsSQL(t *testing.T) {
+	db, statements := dbtest.DryRun(t)
+	ds, err := NewDatabaseStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := ProductFilter{CategoryID: "kadin", MinPrice: try(10000), OnFlashSale: true}
+	if _, err := ds.ListProducts(filter, Page{Number: 3, Size: 20}); err != nil {
+		t.Fatal(err)
+	}
+	sql := statements()
+	if len(sql) != 2 {
+		t.Fatalf("statements = %q, want a count and a select", sql)
+	}
+	for _, want := range []string{"category_id IN", "'kadin'", "discounted_price >= '100.00'", "on_flash_sale"} {
+		for _, s := range sql {
+			if !strings.Contains(s, want) {
+				t.Errorf("%s\ndoes not contain %s", s, want)
+			}
+		}
+	}
+	if !strings.HasPrefix(sql[0], "SELECT count(*)") || strings.Contains(sql[0], "ORDER BY") {
+		t.Errorf("count = %s", sql[0])
+	}
+	if !strings.HasSuffix(sql[1], "ORDER BY id LIMIT 20 OFFSET 40") {
+		t.Errorf("select = %s, want it ordered by id, page 3 of 20", sql[1])
+	}
+}
+
+func TestSearchProductsSQL(t *testing.T) {
+	db, statements := dbtest.DryRun(t)
+	ds, err := NewDatabaseStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.SearchProducts(`elbise 50%_\`); err != nil {
+		t.Fatal(err)
+	}
+	sql := statements()
+	if len(sql) != 1 {
+		t.Fatalf("statements = %q, want one", sql)
+	}
+	for _, want := range []string{
+		`(name ILIKE '%elbise%' OR brand ILIKE '%elbise%')`,
+		`(name ILIKE '%50\%\_\\%' OR brand ILIKE '%50\%\_\\%')`,
+		`ORDER BY name COLLATE "C", id LIMIT 100`,
+	} {
+		if !strings.Contains(sql[0], want) {
+			t.Errorf("%s\ndoes not contain %s", sql[0], want)
+		}
+	}
+}
+
+func TestGetCategoryTreeSQL(t *testing.T) {
+	db, statements := dbtest.DryRun(t)
+	ds, err := NewDatabaseStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.GetCategoryTree(); err != nil {
+		t.Fatal(err)
+	}
+	if sql := statements(); len(sql) != 1 || !strings.HasSuffix(sql[0], `ORDER BY name COLLATE "C", id`) {
+		t.Errorf("statements = %q, want categories in categoryOrder", sql)
+	}
+}
+
+func TestRefreshProductCountsForSQL(t *testing.T) {
+	db, statements := dbtest.DryRun(t)
+	if err := RefreshProductCountsFor(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	if sql := statements(); len(sql) != 0 {
+		t.Errorf("statements = %q, want none without categories", sql)
+	}
+
+	if err := RefreshProductCountsFor(db, []string{"kadin-elbise", "erkek"}); err != nil {
+		t.Fatal(err)
+	}
+	sql := statements()
+	if len(sql) != 1 {
+		t.Fatalf("statements = %q, want one", sql)
+	}
+	for _, want := range []string{"WHERE id IN ('kadin-elbise','erkek')", "JOIN roots ON c.id = roots.parent_id", "UPDATE categories SET product_count"} {
+		if !strings.Contains(sql[0], want) {
+			t.Errorf("%s\ndoes not contain %s", sql[0], want)
+		}
+	}
+}
@@ -2,9 +2,16 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 	"trendyol-scraper/config"
 	"trendyol-scraper/models"
@@ -19,95 +26,330 @@ func NewJSONStorage(cfg *config.Config) *JSONStorage {
 }
 
 func (js *JSONStorage) SaveCategories(categories []models.Category) error {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := filepath.Join(js.outputPath, fmt.Sprintf("categories_%s.json", timestamp))
+	return js.writeSnapshot("categories", categories, true)
+}
 
-	if err := os.MkdirAll(js.outputPath, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
+func (js *JSONStorage) SaveProducts(products []models.Product) error {
+	return js.writeSnapshot("products", products, true)
+}
 
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create JSON file: %w", err)
+func (js *JSONStorage) SaveVariants(variants []models.Variant) error {
+	if len(variants) == 0 {
+		return nil
 	}
-	defer file.Close()
+	return js.writeSnapshot("variants", variants, false)
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(categories); err != nil {
-		return fmt.Errorf("failed to encode categories to JSON: %w", err)
+func (js *JSONStorage) SaveImages(images []models.Image) error {
+	if len(images) == 0 {
+		return nil
 	}
-
-	return nil
+	return js.writeSnapshot("images", images, false)
 }
 
-func (js *JSONStorage) SaveProducts(products []models.Product) error {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := filepath.Join(js.outputPath, fmt.Sprintf("products_%s.json", timestamp))
+// snapshotSeq orders snapshots written within the same nanosecond.
+var snapshotSeq atomic.Uint64
 
+// writeSnapshot writes v to a new file of kind. Names carry the time to the
+// nanosecond and a sequence number, and a file is never overwritten, so
+// every call keeps its own snapshot.
+func (js *JSONStorage) writeSnapshot(kind string, v any, indent bool) error {
 	if err := os.MkdirAll(js.outputPath, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create JSON file: %w", err)
+	var file *os.File
+	for {
+		name := fmt.Sprintf("%s_%s_%06d.json", kind, time.Now().Format(snapshotTimeLayout), snapshotSeq.Add(1))
+		var err error
+		file, err = os.OpenFile(filepath.Join(js.outputPath, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("failed to create JSON file: %w", err)
+		}
 	}
 	defer file.Close()
 
 	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(products); err != nil {
-		return fmt.Errorf("failed to encode products to JSON: %w", err)
+	if indent {
+		encoder.SetIndent("", "  ")
 	}
-
-	return nil
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode %s to JSON: %w", kind, err)
+	}
+	return file.Close()
 }
 
-func (js *JSONStorage) SaveVariants(variants []models.Variant) error {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := filepath.Join(js.outputPath, fmt.Sprintf("variants_%s.json", timestamp))
+// The read methods below work on the files written by the Save methods.
+// Each products file is a snapshot: a product's latest snapshot is its
+// current state, and every snapshot of it is an observation of its price.
 
-	if err := os.MkdirAll(js.outputPath, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+// Snapshot names are <kind>_<time>_<seq>.json; files from before the
+// sequence number was added are <kind>_<time to the second>.json.
+const (
+	snapshotTimeLayout       = "2006-01-02_15-04-05.000000000"
+	legacySnapshotTimeLayout = "2006-01-02_15-04-05"
+)
+
+type snapshot struct {
+	path string
+	at   time.Time
+	seq  uint64
+}
+
+// snapshots lists the files of kind ("products", "variants", ...) oldest
+// first.
+func (js *JSONStorage) snapshots(kind string) ([]snapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(js.outputPath, kind+"_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var files []snapshot
+	for _, path := range paths {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), kind+"_"), ".json")
+		if file, ok := parseSnapshotName(stamp); ok {
+			file.path = path
+			files = append(files, file)
+		}
 	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].at.Equal(files[j].at) {
+			return files[i].at.Before(files[j].at)
+		}
+		return files[i].seq < files[j].seq
+	})
+	return files, nil
+}
 
-	file, err := os.Create(filename)
+func parseSnapshotName(stamp string) (snapshot, bool) {
+	if at, err := time.ParseInLocation(legacySnapshotTimeLayout, stamp, time.Local); err == nil {
+		return snapshot{at: at}, true
+	}
+	i := strings.LastIndex(stamp, "_")
+	if i < 0 {
+		return snapshot{}, false
+	}
+	at, err := time.ParseInLocation(snapshotTimeLayout, stamp[:i], time.Local)
 	if err != nil {
-		return fmt.Errorf("failed to create JSON file: %w", err)
+		return snapshot{}, false
 	}
-	defer file.Close()
+	seq, err := strconv.ParseUint(stamp[i+1:], 10, 64)
+	if err != nil {
+		return snapshot{}, false
+	}
+	return snapshot{at: at, seq: seq}, true
+}
 
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(variants); err != nil {
-		return fmt.Errorf("failed to encode variants to JSON: %w", err)
+// readSnapshot decodes the file at path into v. A file that cannot be
+// decoded is logged and reported as not ok, so one bad snapshot does not
+// break every read.
+func readSnapshot(path string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
 	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Printf("Skipping snapshot %s: %v", path, err)
+		return false, nil
+	}
+	return true, nil
+}
 
-	return nil
+// latestProducts returns the latest snapshot of every product in
+// listOrder.
+func (js *JSONStorage) latestProducts() ([]models.Product, error) {
+	files, err := js.snapshots("products")
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]models.Product)
+	for _, file := range files {
+		var products []models.Product
+		if ok, err := readSnapshot(file.path, &products); !ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, p := range products {
+			byID[p.ID] = p
+		}
+	}
+	products := make([]models.Product, 0, len(byID))
+	for _, p := range byID {
+		products = append(products, p)
+	}
+	productsByID(products)
+	return products, nil
 }
 
-func (js *JSONStorage) SaveImages(images []string) error {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	filename := filepath.Join(js.outputPath, fmt.Sprintf("images_%s.json", timestamp))
+// GetProduct returns the latest snapshot of a product, with the variants
+// and images of the latest files that have any for it.
+func (js *JSONStorage) GetProduct(id int) (*models.Product, error) {
+	products, err := js.latestProducts()
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(products), func(i int) bool { return products[i].ID >= id })
+	if i == len(products) || products[i].ID != id {
+		return nil, fmt.Errorf("product %d: %w", id, ErrNotFound)
+	}
+	product := products[i]
 
-	if err := os.MkdirAll(js.outputPath, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	variantFiles, err := js.snapshots("variants")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range variantFiles {
+		var variants []models.Variant
+		if ok, err := readSnapshot(file.path, &variants); !ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var own []models.Variant
+		for _, v := range variants {
+			if v.ProductID == id {
+				own = append(own, v)
+			}
+		}
+		if len(own) > 0 {
+			product.Variants = own
+		}
 	}
 
-	file, err := os.Create(filename)
+	imageFiles, err := js.snapshots("images")
 	if err != nil {
-		return fmt.Errorf("failed to create JSON file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
+	for _, file := range imageFiles {
+		var images []models.Image
+		if ok, err := readSnapshot(file.path, &images); !ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var own []models.Image
+		for _, img := range images {
+			if img.ProductID == id {
+				own = append(own, img)
+			}
+		}
+		if len(own) > 0 {
+			sort.SliceStable(own, func(i, j int) bool { return own[i].Position < own[j].Position })
+			product.Images = own
+		}
+	}
+	return &product, nil
+}
 
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(images); err != nil {
-		return fmt.Errorf("failed to encode images to JSON: %w", err)
+// GetPriceHistory treats every products file that has the product as one
+// observation, recorded at the file's timestamp.
+func (js *JSONStorage) GetPriceHistory(productID int, from, to time.Time) ([]models.PriceHistory, error) {
+	files, err := js.snapshots("products")
+	if err != nil {
+		return nil, err
+	}
+	var history []models.PriceHistory
+	var last *models.PriceHistory
+	for _, file := range files {
+		var products []models.Product
+		if ok, err := readSnapshot(file.path, &products); !ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, p := range products {
+			if p.ID != productID {
+				continue
+			}
+			observation := models.ObservePrice(p, true)
+			observation.RecordedAt = file.at
+			if last != nil {
+				observation.Changed = !observation.SamePrice(*last)
+			}
+			last = &observation
+			if (from.IsZero() || !file.at.Before(from)) && (to.IsZero() || !file.at.After(to)) {
+				history = append(history, observation)
+			}
+			break
+		}
+	}
+	return history, nil
+}
+
+func (js *JSONStorage) ListProducts(filter ProductFilter, page Page) (*ProductList, error) {
+	page = page.normalize()
+	products, err := js.latestProducts()
+	if err != nil {
+		return nil, err
+	}
+	var categories map[string]bool
+	if filter.CategoryID != "" {
+		tree, err := js.GetCategoryTree()
+		if err != nil {
+			return nil, err
+		}
+		categories = subtreeIDs(tree, filter.CategoryID)
 	}
 
-	return nil
+	var matched []models.Product
+	for _, p := range products {
+		if filter.matches(p, categories) {
+			matched = append(matched, p)
+		}
+	}
+	list := &ProductList{Total: int64(len(matched)), Page: page}
+	if start := page.offset(); start < len(matched) {
+		list.Products = matched[start:min(start+page.Size, len(matched))]
+	}
+	return list, nil
 }
 
-func (js *JSONStorage) GetProduct(id int) (*models.Product, error) {
-	return nil, fmt.Errorf("GetProduct not implemented for JSON storage")
+func (js *JSONStorage) SearchProducts(query string) ([]models.Product, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	products, err := js.latestProducts()
+	if err != nil {
+		return nil, err
+	}
+	var found []models.Product
+	for _, p := range products {
+		if matchesSearch(p, terms) {
+			found = append(found, p)
+		}
+	}
+	productsByName(found)
+	if len(found) > searchLimit {
+		found = found[:searchLimit]
+	}
+	return found, nil
+}
+
+// GetCategoryTree returns the tree of the latest categories file that can
+// be read, or nil when none was saved yet.
+func (js *JSONStorage) GetCategoryTree() ([]models.Category, error) {
+	files, err := js.snapshots("categories")
+	if err != nil {
+		return nil, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		var categories []models.Category
+		ok, err := readSnapshot(files[i].path, &categories)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sortCategories(categories)
+			return categories, nil
+		}
+	}
+	return nil, nil
 }
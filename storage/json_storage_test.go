@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"trendyol-scraper/models"
+)
+
+func newTestJSONStorage(t *testing.T) *JSONStorage {
+	return &JSONStorage{outputPath: t.TempDir()}
+}
+
+func TestJSONStorageReadAPI(t *testing.T) {
+	testReadAPI(t, newTestJSONStorage(t))
+}
+
+func TestJSONStorageEmpty(t *testing.T) {
+	js := &JSONStorage{outputPath: filepath.Join(t.TempDir(), "never-written")}
+	if _, err := js.GetProduct(1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetProduct error = %v, want ErrNotFound", err)
+	}
+	if tree, err := js.GetCategoryTree(); err != nil || tree != nil {
+		t.Errorf("GetCategoryTree = %v, %v; want nil", tree, err)
+	}
+	if list, err := js.ListProducts(ProductFilter{}, Page{}); err != nil || list.Total != 0 {
+		t.Errorf("ListProducts = %+v, %v; want none", list, err)
+	}
+}
+
+// copyLegacy copies the snapshots in testdata/legacy, written before
+// snapshot names had a sequence number, to a new output directory.
+func copyLegacy(t *testing.T) string {
+	dir := t.TempDir()
+	paths, err := filepath.Glob("testdata/legacy/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no legacy snapshots: %v", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(path)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestJSONStorageLegacySnapshots(t *testing.T) {
+	js := &JSONStorage{outputPath: copyLegacy(t)}
+
+	// The legacy files have "categoryId": 0 and "promotionEndDate": {}
+	p, err := js.GetProduct(900904457)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CategoryID != nil {
+		t.Errorf("CategoryID = %q, want nil for 0", *p.CategoryID)
+	}
+	if want := (models.Money{Amount: 1363, Currency: "AED"}); p.Price.DiscountedPrice != want {
+		t.Errorf("discounted price = %v, want %v", p.Price.DiscountedPrice, want)
+	}
+	if len(p.Promotions) != 1 || !p.Promotions[0].PromotionEndDate.Time().IsZero() {
+		t.Errorf("promotions = %+v, want one without an end date", p.Promotions)
+	}
+
+	history, err := js.GetPriceHistory(900904457, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{
+		time.Date(2025, 4, 25, 13, 1, 2, 0, time.Local),
+		time.Date(2025, 4, 25, 13, 3, 8, 0, time.Local),
+	}
+	if len(history) != len(want) {
+		t.Fatalf("%d observations, want %d", len(history), len(want))
+	}
+	for i, h := range history {
+		if !h.RecordedAt.Equal(want[i]) {
+			t.Errorf("observation %d recorded at %v, want the file time %v", i, h.RecordedAt, want[i])
+		}
+	}
+	if !history[0].Changed || history[1].Changed {
+		t.Errorf("changed = %v, %v; want only the first observation changed", history[0].Changed, history[1].Changed)
+	}
+
+	// A snapshot written now sorts after the legacy ones
+	update := testProduct(900904457, p.Name, p.Brand, p.BrandID, "", 1200)
+	update.Price.SetCurrency("AED")
+	if err := js.SaveProducts([]models.Product{update}); err != nil {
+		t.Fatal(err)
+	}
+	p, err = js.GetProduct(900904457)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (models.Money{Amount: 1200, Currency: "AED"}); p.Price.DiscountedPrice != want {
+		t.Errorf("discounted price after an update = %v, want %v", p.Price.DiscountedPrice, want)
+	}
+	history, err = js.GetPriceHistory(900904457, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || !history[2].Changed {
+		t.Errorf("history = %+v, want a third, changed observation", history)
+	}
+}
+
+func TestJSONStorageSkipsUnreadableSnapshots(t *testing.T) {
+	dir := copyLegacy(t)
+	for _, name := range []string{"products_2025-04-25_13-02-00.json", "categories_2099-01-01_00-00-00.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`[{"id": `), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	js := &JSONStorage{outputPath: dir}
+	if err := js.SaveCategories(testCategories()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := js.GetProduct(900904457); err != nil {
+		t.Errorf("GetProduct: %v", err)
+	}
+	if history, err := js.GetPriceHistory(900904457, time.Time{}, time.Time{}); err != nil || len(history) != 2 {
+		t.Errorf("GetPriceHistory = %d observations, %v; want the 2 readable ones", len(history), err)
+	}
+	// The broken categories file is the newest; the one before it is used
+	if tree, err := js.GetCategoryTree(); err != nil || len(tree) != 2 {
+		t.Errorf("GetCategoryTree = %v, %v; want the saved tree", tree, err)
+	}
+}
+
+func TestJSONStoragePriceHistoryRange(t *testing.T) {
+	dir := t.TempDir()
+	js := &JSONStorage{outputPath: dir}
+	prices := []int64{1000, 1000, 900, 900}
+	for i, price := range prices {
+		product := testProduct(1, "Kılıf", "Choice", 1, "", price)
+		name := "products_2025-05-0" + string(rune('1'+i)) + "_12-00-00.json"
+		writeJSON(t, filepath.Join(dir, name), []models.Product{product})
+	}
+
+	tests := []struct {
+		name        string
+		from, to    time.Time
+		wantDays    []int
+		wantChanged []bool
+	}{
+		{name: "open", wantDays: []int{1, 2, 3, 4}, wantChanged: []bool{true, false, true, false}},
+		{
+			// Changed is relative to the observation before from
+			name:        "from",
+			from:        time.Date(2025, 5, 2, 12, 0, 0, 0, time.Local),
+			wantDays:    []int{2, 3, 4},
+			wantChanged: []bool{false, true, false},
+		},
+		{
+			name:        "from and to",
+			from:        time.Date(2025, 5, 2, 0, 0, 0, 0, time.Local),
+			to:          time.Date(2025, 5, 3, 12, 0, 0, 0, time.Local),
+			wantDays:    []int{2, 3},
+			wantChanged: []bool{false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history, err := js.GetPriceHistory(1, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != len(tt.wantDays) {
+				t.Fatalf("%d observations, want %d", len(history), len(tt.wantDays))
+			}
+			for i, h := range history {
+				if h.RecordedAt.Day() != tt.wantDays[i] || h.Changed != tt.wantChanged[i] {
+					t.Errorf("observation %d = day %d changed %v, want day %d changed %v",
+						i, h.RecordedAt.Day(), h.Changed, tt.wantDays[i], tt.wantChanged[i])
+				}
+			}
+		})
+	}
+}
+
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONStorageSnapshotNames(t *testing.T) {
+	js := newTestJSONStorage(t)
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 1; i <= writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := js.SaveProducts([]models.Product{testProduct(i, "p", "b", 1, "", 100)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	files, err := js.snapshots("products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != writers {
+		t.Fatalf("%d snapshots, want one per call", len(files))
+	}
+	for i := 1; i < len(files); i++ {
+		prev, cur := files[i-1], files[i]
+		if cur.at.Before(prev.at) || cur.at.Equal(prev.at) && cur.seq <= prev.seq {
+			t.Errorf("snapshots %s and %s are out of order", prev.path, cur.path)
+		}
+	}
+}
+
+func TestParseSnapshotName(t *testing.T) {
+	tests := []struct {
+		stamp   string
+		wantOK  bool
+		wantAt  time.Time
+		wantSeq uint64
+	}{
+		{"2025-04-25_13-01-02", true, time.Date(2025, 4, 25, 13, 1, 2, 0, time.Local), 0},
+		{"2025-04-25_13-01-02.000000500_000042", true, time.Date(2025, 4, 25, 13, 1, 2, 500, time.Local), 42},
+		{"2025-04-25_13-01-02_000042", false, time.Time{}, 0},
+		{"2025-04-25_13-01-02.000000500_x", false, time.Time{}, 0},
+		{"latest", false, time.Time{}, 0},
+	}
+	for _, tt := range tests {
+		got, ok := parseSnapshotName(tt.stamp)
+		if ok != tt.wantOK || !got.at.Equal(tt.wantAt) || got.seq != tt.wantSeq {
+			t.Errorf("parseSnapshotName(%q) = %v %d %v, want %v %d %v",
+				tt.stamp, got.at, got.seq, ok, tt.wantAt, tt.wantSeq, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"time"
+	"trendyol-scraper/models"
+
+	"gorm.io/gorm"
+)
+
+// The price history is a time series of models.PriceHistory observations
+// per product. The functions take a *gorm.DB so they can run inside the
+// caller's transaction. A zero from or to leaves that end of a range open.
+
+// RecordPrice stores an observation of product's price, marking it as
+// changed when it differs from the last one recorded, and returns it.
+func RecordPrice(db *gorm.DB, product models.Product) (models.PriceHistory, error) {
+	last, err := LastKnownPrice(db, product.ID, time.Time{})
+	if err != nil {
+		return models.PriceHistory{}, err
+	}
+	observation := models.ObservePrice(product, last == nil)
+	if last != nil {
+		observation.Changed = !observation.SamePrice(*last)
+	}
+	if err := db.Create(&observation).Error; err != nil {
+		return models.PriceHistory{}, err
+	}
+	return observation, nil
+}
+
+// PriceRange returns the observations of a product between from and to,
+// oldest first. With changesOnly, heartbeats are left out.
+func PriceRange(db *gorm.DB, productID int, from, to time.Time, changesOnly bool) ([]models.PriceHistory, error) {
+	query := between(db.Where("product_id = ?", productID), from, to)
+	if changesOnly {
+		query = query.Where("changed")
+	}
+	var history []models.PriceHistory
+	if err := query.Order("recorded_at, id").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// PriceStats summarizes a product's price over a range.
+type PriceStats struct {
+	Min          models.PriceHistory // observation with the lowest discounted price
+	Max          models.PriceHistory // observation with the highest discounted price
+	Observations int64
+}
+
+// PriceExtremes returns the lowest and highest discounted price of a
+// product between from and to, the earliest observation winning ties. It
+// returns nil when nothing was observed in the range.
+func PriceExtremes(db *gorm.DB, productID int, from, to time.Time) (*PriceStats, error) {
+	scope := func() *gorm.DB {
+		return between(db.Model(&models.PriceHistory{}).Where("product_id = ?", productID), from, to)
+	}
+
+	var stats PriceStats
+	if err := scope().Count(&stats.Observations).Error; err != nil {
+		return nil, err
+	}
+	if stats.Observations == 0 {
+		return nil, nil
+	}
+	if err := scope().Order("discounted_price, recorded_at").Take(&stats.Min).Error; err != nil {
+		return nil, err
+	}
+	if err := scope().Order("discounted_price DESC, recorded_at").Take(&stats.Max).Error; err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// LastKnownPrice returns the latest observation of a product at or before
+// at, or the latest overall when at is zero. It returns nil when the
+// product has none.
+func LastKnownPrice(db *gorm.DB, productID int, at time.Time) (*models.PriceHistory, error) {
+	var last models.PriceHistory
+	err := between(db.Where("product_id = ?", productID), time.Time{}, at).
+		Order("recorded_at DESC, id DESC").Take(&last).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &last, nil
+}
+
+func between(query *gorm.DB, from, to time.Time) *gorm.DB {
+	if !from.IsZero() {
+		query = query.Where("recorded_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("recorded_at <= ?", to)
+	}
+	return query
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"trendyol-scraper/internal/dbtest"
+	"trendyol-scraper/models"
+
+	"gorm.io/gorm"
+)
+
+func may(day int) time.Time { return time.Date(2025, 5, day, 12, 0, 0, 0, time.UTC) }
+
+// observe stores one observation of product 1 per price, a day apart from
+// May 1.
+func observe(t *testing.T, db *gorm.DB, prices ...int64) {
+	t.Helper()
+	ds, err := NewDatabaseStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.SaveProducts([]models.Product{testProduct(1, "Kılıf", "Choice", 1, "", prices[0])}); err != nil {
+		t.Fatal(err)
+	}
+	for i, price := range prices {
+		observation := models.ObservePrice(testProduct(1, "Kılıf", "Choice", 1, "", price), true)
+		observation.RecordedAt = may(i + 1)
+		if err := db.Create(&observation).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecordPrice(t *testing.T) {
+	db := dbtest.Open(t)
+	ds, err := NewDatabaseStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.SaveProducts([]models.Product{testProduct(1, "Kılıf", "Choice", 1, "", 1000)}); err != nil {
+		t.Fatal(err)
+	}
+
+	sale := testProduct(1, "Kılıf", "Choice", 1, "", 1000)
+	sale.OnFlashSale = true
+	dirham := testProduct(1, "Kılıf", "Choice", 1, "", 900)
+	dirham.Price.SetCurrency("AED")
+	tests := []struct {
+		name        string
+		product     models.Product
+		wantChanged bool
+	}{
+		{name: "first observation", product: testProduct(1, "Kılıf", "Choice", 1, "", 1000), wantChanged: true},
+		{name: "heartbeat", product: testProduct(1, "Kılıf", "Choice", 1, "", 1000)},
+		{name: "flash sale", product: sale, wantChanged: true},
+		{name: "drop", product: testProduct(1, "Kılıf", "Choice", 1, "", 900), wantChanged: true},
+		{name: "same amount in another currency", product: dirham, wantChanged: true},
+	}
+	for _, tt := range tests {
+		observation, err := RecordPrice(db, tt.product)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if observation.Changed != tt.wantChanged {
+			t.Errorf("%s: changed = %v, want %v", tt.name, observation.Changed, tt.wantChanged)
+		}
+	}
+
+	changes, err := PriceRange(db, 1, time.Time{}, time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 {
+		t.Errorf("%d changes recorded, want 4 besides the heartbeat", len(changes))
+	}
+}
+
+func TestPriceExtremes(t *testing.T) {
+	db := dbtest.Open(t)
+	observe(t, db, 1000, 800, 1200, 800, 1200)
+
+	tests := []struct {
+		name             string
+		from, to         time.Time
+		wantMin, wantMax int // day of the observation
+		wantCount        int64
+	}{
+		{name: "all", wantMin: 2, wantMax: 3, wantCount: 5},
+		{name: "from May 4", from: may(4), wantMin: 4, wantMax: 5, wantCount: 2},
+		{name: "until May 1", to: may(1), wantMin: 1, wantMax: 1, wantCount: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := PriceExtremes(db, 1, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats == nil {
+				t.Fatal("no stats")
+			}
+			if stats.Min.RecordedAt.Day() != tt.wantMin || stats.Max.RecordedAt.Day() != tt.wantMax || stats.Observations != tt.wantCount {
+				t.Errorf("min May %d, max May %d of %d observations; want May %d, May %d of %d",
+					stats.Min.RecordedAt.Day(), stats.Max.RecordedAt.Day(), stats.Observations, tt.wantMin, tt.wantMax, tt.wantCount)
+			}
+			if stats.Min.DiscountedPrice.Currency != "TRY" {
+				t.Errorf("min price %v has no currency", stats.Min.DiscountedPrice)
+			}
+		})
+	}
+
+	if stats, err := PriceExtremes(db, 1, may(10), time.Time{}); err != nil || stats != nil {
+		t.Errorf("PriceExtremes() with no observations = %+v, %v; want nil", stats, err)
+	}
+}
+
+func TestLastKnownPrice(t *testing.T) {
+	db := dbtest.Open(t)
+	observe(t, db, 1000, 900, 800)
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		wantDay int // 0 for none
+	}{
+		{name: "latest", wantDay: 3},
+		{name: "between observations", at: may(2).Add(time.Hour), wantDay: 2},
+		{name: "at an observation", at: may(1), wantDay: 1},
+		{name: "before any", at: may(1).Add(-time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last, err := LastKnownPrice(db, 1, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			day := 0
+			if last != nil {
+				day = last.RecordedAt.Day()
+			}
+			if day != tt.wantDay {
+				t.Errorf("last known price from May %d, want May %d", day, tt.wantDay)
+			}
+		})
+	}
+}
+
+func TestLastKnownPriceSQL(t *testing.T) {
+	db, statements := dbtest.DryRun(t)
+	if _, err := LastKnownPrice(db, 7, may(2)); err != nil {
+		t.Fatal(err)
+	}
+	sql := statements()
+	if len(sql) != 1 {
+		t.Fatalf("statements = %q, want one", sql)
+	}
+	for _, want := range []string{"product_id = 7", "recorded_at <= '2025-05-02 12:00:00", "ORDER BY recorded_at DESC, id DESC LIMIT 1"} {
+		if !strings.Contains(sql[0], want) {
+			t.Errorf("%s\ndoes not contain %s", sql[0], want)
+		}
+	}
+	if strings.Contains(sql[0], "recorded_at >=") {
+		t.Errorf("%s\nhas a lower bound", sql[0])
+	}
+}
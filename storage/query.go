@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"trendyol-scraper/models"
+)
+
+// ProductFilter narrows ListProducts. Zero fields do not filter.
+type ProductFilter struct {
+	CategoryID           string // the category and its subcategories
+	BrandID              int
+	MinPrice             models.Money // on the discounted price
+	MaxPrice             models.Money
+	OnFlashSale          bool
+	HasCollectableCoupon bool
+}
+
+// Page selects a page of results. Number starts at 1; a zero Size means
+// DefaultPageSize, and sizes above MaxPageSize are capped.
+type Page struct {
+	Number int
+	Size   int
+}
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+
+	// searchLimit caps SearchProducts results.
+	searchLimit = 100
+)
+
+func (p Page) normalize() Page {
+	if p.Number < 1 {
+		p.Number = 1
+	}
+	if p.Size <= 0 {
+		p.Size = DefaultPageSize
+	}
+	if p.Size > MaxPageSize {
+		p.Size = MaxPageSize
+	}
+	return p
+}
+
+func (p Page) offset() int {
+	return (p.Number - 1) * p.Size
+}
+
+// Result orders, the same for both backends. Names compare byte by byte
+// (COLLATE "C" in Postgres) so the database and the files agree.
+const (
+	listOrder     = "id"
+	searchOrder   = `name COLLATE "C", id`
+	categoryOrder = `name COLLATE "C", id`
+)
+
+func productsByID(products []models.Product) {
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+}
+
+func productsByName(products []models.Product) {
+	sort.Slice(products, func(i, j int) bool {
+		if products[i].Name != products[j].Name {
+			return products[i].Name < products[j].Name
+		}
+		return products[i].ID < products[j].ID
+	})
+}
+
+// sortCategories orders every level of a category tree like categoryOrder.
+func sortCategories(categories []models.Category) {
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Name != categories[j].Name {
+			return categories[i].Name < categories[j].Name
+		}
+		return categories[i].ID < categories[j].ID
+	})
+	for i := range categories {
+		sortCategories(categories[i].Children)
+	}
+}
+
+// ProductList is one page of ListProducts, ordered by product ID.
+type ProductList struct {
+	Products []models.Product
+	Total    int64 // products matching the filter across all pages
+	Page     Page
+}
+
+// matches applies f to p; categories holds the filter's category and its
+// subcategories.
+func (f ProductFilter) matches(p models.Product, categories map[string]bool) bool {
+	if f.CategoryID != "" && (p.CategoryID == nil || !categories[*p.CategoryID]) {
+		return false
+	}
+	if f.BrandID != 0 && p.BrandID != f.BrandID {
+		return false
+	}
+	price := p.Price.DiscountedPrice
+	if !f.MinPrice.IsZero() && price.Less(f.MinPrice) {
+		return false
+	}
+	if !f.MaxPrice.IsZero() && f.MaxPrice.Less(price) {
+		return false
+	}
+	if f.OnFlashSale && !p.OnFlashSale {
+		return false
+	}
+	if f.HasCollectableCoupon && !p.HasCollectableCoupon {
+		return false
+	}
+	return true
+}
+
+// searchTerms splits a search query into lower-cased words; a product
+// matches when every word occurs in its name or brand.
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+func matchesSearch(p models.Product, terms []string) bool {
+	text := strings.ToLower(p.Name + " " + p.Brand)
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// buildCategoryTree links flat categories into trees by ParentID, children
+// in the order given. Categories whose parent is unknown become roots.
+func buildCategoryTree(flat []models.Category) []models.Category {
+	byID := make(map[string]bool, len(flat))
+	children := make(map[string][]models.Category)
+	for _, c := range flat {
+		byID[c.ID] = true
+	}
+	var roots []models.Category
+	for _, c := range flat {
+		if c.ParentID != nil && byID[*c.ParentID] {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	var attach func(cats []models.Category) []models.Category
+	attach = func(cats []models.Category) []models.Category {
+		for i := range cats {
+			cats[i].Children = attach(children[cats[i].ID])
+		}
+		return cats
+	}
+	return attach(roots)
+}
+
+// subtreeIDs returns id and the IDs of every category below it in tree.
+func subtreeIDs(tree []models.Category, id string) map[string]bool {
+	ids := map[string]bool{id: true}
+	var walk func(cats []models.Category, inside bool)
+	walk = func(cats []models.Category, inside bool) {
+		for _, c := range cats {
+			in := inside || c.ID == id
+			if in {
+				ids[c.ID] = true
+			}
+			walk(c.Children, in)
+		}
+	}
+	walk(tree, false)
+	return ids
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"trendyol-scraper/models"
+)
+
+func strPtr(s string) *string { return &s }
+
+func try(amount int64) models.Money { return models.Money{Amount: amount, Currency: "TRY"} }
+
+// testCategories is a tree of two roots, saved in no particular order.
+func testCategories() []models.Category {
+	return []models.Category{
+		{ID: "kadin", Name: "Kadın", Children: []models.Category{
+			{ID: "kadin-elbise", Name: "Elbise", ParentID: strPtr("kadin"), IsLeaf: true},
+			{ID: "kadin-ayakkabi", Name: "Ayakkabı", ParentID: strPtr("kadin"), IsLeaf: true},
+		}},
+		{ID: "erkek", Name: "Erkek", IsLeaf: true},
+	}
+}
+
+func testProduct(id int, name, brand string, brandID int, category string, price int64) models.Product {
+	p := models.Product{ID: id, Name: name, Brand: brand, BrandID: brandID}
+	if category != "" {
+		p.CategoryID = strPtr(category)
+	}
+	p.Price.SellingPrice = try(price)
+	p.Price.DiscountedPrice = try(price)
+	p.Price.OriginalPrice = try(price)
+	p.Price.SetCurrency("TRY")
+	return p
+}
+
+func testProducts() []models.Product {
+	products := []models.Product{
+		testProduct(1, "Zeytin Elbise", "Koton", 10, "kadin-elbise", 10000),
+		testProduct(2, "elbise mini", "Mavi", 20, "kadin-elbise", 5000),
+		testProduct(3, "Spor Ayakkabi", "Nike", 30, "kadin-ayakkabi", 50000),
+		testProduct(4, "Gomlek", "Koton", 10, "erkek", 20000),
+		testProduct(5, "Zeytin Elbise", "LC Waikiki", 40, "", 7500),
+	}
+	products[0].OnFlashSale = true
+	products[2].HasCollectableCoupon = true
+	return products
+}
+
+func productIDs(products []models.Product) []int {
+	ids := []int{}
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+type categoryNode struct {
+	ID       string
+	Children []categoryNode
+}
+
+func categoryNodes(categories []models.Category) []categoryNode {
+	nodes := []categoryNode{}
+	for _, c := range categories {
+		nodes = append(nodes, categoryNode{ID: c.ID, Children: categoryNodes(c.Children)})
+	}
+	return nodes
+}
+
+// testReadAPI saves the same data through s and checks what the read
+// methods return, so both backends are held to one set of results and
+// orders.
+func testReadAPI(t *testing.T, s StorageHandler) {
+	if err := s.SaveCategories(testCategories()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveProducts(testProducts()); err != nil {
+		t.Fatal(err)
+	}
+	variants := []models.Variant{
+		{ProductID: 3, SKU: "3-42", Attribute: "Beden", Value: "42", Available: true},
+		{ProductID: 3, SKU: "3-43", Attribute: "Beden", Value: "43"},
+	}
+	if err := s.SaveVariants(variants); err != nil {
+		t.Fatal(err)
+	}
+	images := []models.Image{
+		{ProductID: 3, Position: 1, URL: "https://cdn.example.com/3-b.jpg"},
+		{ProductID: 3, Position: 0, URL: "https://cdn.example.com/3-a.jpg"},
+	}
+	if err := s.SaveImages(images); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("GetProduct", func(t *testing.T) {
+		p, err := s.GetProduct(3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Name != "Spor Ayakkabi" || p.Price.DiscountedPrice != try(50000) {
+			t.Errorf("product 3 = %q at %v", p.Name, p.Price.DiscountedPrice)
+		}
+		var skus []string
+		for _, v := range p.Variants {
+			skus = append(skus, v.SKU)
+		}
+		if !reflect.DeepEqual(skus, []string{"3-42", "3-43"}) {
+			t.Errorf("variants = %v, want 3-42 and 3-43", skus)
+		}
+		var urls []string
+		for _, img := range p.Images {
+			urls = append(urls, img.URL)
+		}
+		if !reflect.DeepEqual(urls, []string{"https://cdn.example.com/3-a.jpg", "https://cdn.example.com/3-b.jpg"}) {
+			t.Errorf("images = %v, want them by position", urls)
+		}
+
+		if _, err := s.GetProduct(99); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetProduct(99) error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ListProducts", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			filter    ProductFilter
+			page      Page
+			wantTotal int64
+			wantIDs   []int
+		}{
+			{name: "all", wantTotal: 5, wantIDs: []int{1, 2, 3, 4, 5}},
+			{name: "first page", page: Page{Number: 1, Size: 2}, wantTotal: 5, wantIDs: []int{1, 2}},
+			{name: "last page", page: Page{Number: 3, Size: 2}, wantTotal: 5, wantIDs: []int{5}},
+			{name: "past the end", page: Page{Number: 4, Size: 2}, wantTotal: 5, wantIDs: []int{}},
+			{name: "category with subcategories", filter: ProductFilter{CategoryID: "kadin"}, wantTotal: 3, wantIDs: []int{1, 2, 3}},
+			{name: "leaf category", filter: ProductFilter{CategoryID: "kadin-elbise"}, wantTotal: 2, wantIDs: []int{1, 2}},
+			{name: "unknown category", filter: ProductFilter{CategoryID: "yok"}, wantIDs: []int{}},
+			{name: "brand", filter: ProductFilter{BrandID: 10}, wantTotal: 2, wantIDs: []int{1, 4}},
+			{name: "price range is inclusive", filter: ProductFilter{MinPrice: try(10000), MaxPrice: try(20000)}, wantTotal: 2, wantIDs: []int{1, 4}},
+			{name: "flash sale", filter: ProductFilter{OnFlashSale: true}, wantTotal: 1, wantIDs: []int{1}},
+			{name: "collectable coupon", filter: ProductFilter{HasCollectableCoupon: true}, wantTotal: 1, wantIDs: []int{3}},
+			{name: "filters combine", filter: ProductFilter{CategoryID: "kadin", BrandID: 10}, wantTotal: 1, wantIDs: []int{1}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				list, err := s.ListProducts(tt.filter, tt.page)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if list.Total != tt.wantTotal {
+					t.Errorf("Total = %d, want %d", list.Total, tt.wantTotal)
+				}
+				if got := productIDs(list.Products); !reflect.DeepEqual(got, tt.wantIDs) {
+					t.Errorf("products = %v, want %v", got, tt.wantIDs)
+				}
+			})
+		}
+	})
+
+	t.Run("SearchProducts", func(t *testing.T) {
+		tests := []struct {
+			query   string
+			wantIDs []int
+		}{
+			// Byte order puts upper case first; equal names go by ID
+			{"elbise", []int{1, 5, 2}},
+			{"  ELBISE  ", []int{1, 5, 2}},
+			{"koton elbise", []int{1}},
+			{"koton", []int{4, 1}},
+			{"50%", []int{}},
+			{"_", []int{}},
+		}
+		for _, tt := range tests {
+			found, err := s.SearchProducts(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := productIDs(found); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("SearchProducts(%q) = %v, want %v", tt.query, got, tt.wantIDs)
+			}
+		}
+		if found, err := s.SearchProducts("   "); err != nil || found != nil {
+			t.Errorf("SearchProducts of a blank query = %v, %v; want nil", found, err)
+		}
+	})
+
+	t.Run("GetCategoryTree", func(t *testing.T) {
+		tree, err := s.GetCategoryTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []categoryNode{
+			{ID: "erkek", Children: []categoryNode{}},
+			{ID: "kadin", Children: []categoryNode{
+				{ID: "kadin-ayakkabi", Children: []categoryNode{}},
+				{ID: "kadin-elbise", Children: []categoryNode{}},
+			}},
+		}
+		if got := categoryNodes(tree); !reflect.DeepEqual(got, want) {
+			t.Errorf("tree = %+v, want %+v", got, want)
+		}
+	})
+}